@@ -0,0 +1,20 @@
+// logview renders JSON logs in the console layout of the log package,
+// decodes its binary logs and decrypts its encrypted logs.
+package main
+
+import (
+	"github.com/ensn1to/go-pkg/pkg/app"
+	"github.com/ensn1to/go-pkg/pkg/logview"
+)
+
+func main() {
+	app.NewApp(
+		"Log viewer",
+		"logview",
+		app.WithDescription("logview pretty prints and filters the JSON logs of the log package,\n"+
+			"decodes its binary logs and decrypts its encrypted logs, see \"logview view --help\",\n"+
+			"\"logview decode --help\" and \"logview decrypt --help\"."),
+		app.WithNoConfig(),
+		app.WithCommands(logview.NewCommand(), logview.NewDecodeCommand(), logview.NewDecryptCommand()),
+	).Run()
+}
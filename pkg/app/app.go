@@ -78,6 +78,13 @@ func WithDefaultValidArgs() Option {
 	}
 }
 
+// WithCommands adds sub commands to the application
+func WithCommands(commands ...*Command) Option {
+	return func(a *App) {
+		a.commands = append(a.commands, commands...)
+	}
+}
+
 // RunCommand the app's startup callback function
 type RunFunc func(basename string) error
 
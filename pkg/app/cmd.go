@@ -58,11 +58,22 @@ func NewCommand(usage, desc string, opts ...CommandOption) *Command {
 	return c
 }
 
+// AddCommand adds a sub command to the command
+func (c *Command) AddCommand(cmd *Command) {
+	c.commands = append(c.commands, cmd)
+}
+
+// AddCommands adds multiple sub commands to the command
+func (c *Command) AddCommands(cmds ...*Command) {
+	c.commands = append(c.commands, cmds...)
+}
+
 // cobraCommand create sub command and generate cmd tree
 func (c *Command) cobraCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   c.usage,
 		Short: c.desc,
+		Long:  c.desc,
 	}
 
 	cmd.SetOut(os.Stdout)
@@ -81,13 +92,19 @@ func (c *Command) cobraCommand() *cobra.Command {
 	}
 
 	// sub command flags
+	var namedFlagSets NamedFlagSets
 	if c.options != nil {
-		for _, f := range c.options.Flags().FlagSets {
+		namedFlagSets = c.options.Flags()
+		for _, f := range namedFlagSets.FlagSets {
 			cmd.Flags().AddFlagSet(f)
 		}
 	}
 
-	addHelpCommandFlag(c.usage, cmd.Flags())
+	addHelpCommandFlag(c.usage, namedFlagSets.FlagSet("global"))
+	cmd.Flags().AddFlagSet(namedFlagSets.FlagSet("global"))
+
+	// sections of the command flags, the app ones are inherited otherwise
+	addCmdTemplate(cmd, namedFlagSets)
 
 	return cmd
 }
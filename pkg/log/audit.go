@@ -0,0 +1,295 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// AuditSuccess outcome of an action which succeeded
+	AuditSuccess = "success"
+	// AuditFailure outcome of an action which failed
+	AuditFailure = "failure"
+	// AuditDenied outcome of an action which was not permitted
+	AuditDenied = "denied"
+)
+
+var (
+	// ErrAuditTampered is returned by the verifier when the hash chain of an
+	// audit trail is broken.
+	ErrAuditTampered = errors.New("audit trail tampered")
+	// ErrAuditTruncated is returned by the verifier when the trail ends
+	// before the expected head
+	ErrAuditTruncated = errors.New("audit trail truncated")
+)
+
+// AuditEvent who changed what
+type AuditEvent struct {
+	Actor    string
+	Action   string
+	Resource string
+	Outcome  string
+	// RequestID is taken from the context when empty
+	RequestID string
+}
+
+// AuditRecord is one line of an audit trail. Hash is the HMAC-SHA256 of
+// the record including PrevHash, the hash of the previous record, so that
+// the trail can't be rewritten without the key.
+type AuditRecord struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"timestamp"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Resource  string    `json:"resource"`
+	Outcome   string    `json:"outcome"`
+	RequestID string    `json:"requestID,omitempty"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash,omitempty"`
+}
+
+// AuditHead identifies the last record of an audit trail
+type AuditHead struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// AuditLogger writes hash chained audit records to a file. Unlike the
+// operational logger, records are never sampled or filtered by level and
+// every write is synced to disk before Log returns.
+type AuditLogger struct {
+	mu   sync.Mutex
+	key  []byte
+	file *os.File
+	head AuditHead
+	// size of the trail up to the last record written, a failed write is
+	// truncated back to it
+	size int64
+	// err is set when a failed write couldn't be truncated, the trail is
+	// then closed to new records
+	err error
+}
+
+// NewAuditLogger opens the audit trail at path, appending to it if it
+// exists, its records are chained with key. An existing trail is verified
+// first and rejected if tampered, or if it ends before expected, the head
+// kept by the last run, when not nil.
+func NewAuditLogger(path string, key []byte, expected *AuditHead) (*AuditLogger, error) {
+	if len(key) == 0 {
+		return nil, errors.New("audit trail without key")
+	}
+
+	head, err := VerifyAuditFile(path, key, expected)
+	if errors.Is(err, os.ErrNotExist) && (expected == nil || expected.Seq == 0) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	return &AuditLogger{
+		key:  append([]byte(nil), key...),
+		file: f,
+		head: head,
+		size: info.Size(),
+	}, nil
+}
+
+// Log appends the event to the audit trail
+func (a *AuditLogger) Log(ctx context.Context, e AuditEvent) error {
+	if e.RequestID == "" && ctx != nil {
+		if requestID, ok := ctx.Value(keyRequestID).(string); ok {
+			e.RequestID = requestID
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil {
+		return os.ErrClosed
+	}
+	if a.err != nil {
+		return a.err
+	}
+
+	record := AuditRecord{
+		Seq:       a.head.Seq + 1,
+		Time:      time.Now().UTC(),
+		Actor:     e.Actor,
+		Action:    e.Action,
+		Resource:  e.Resource,
+		Outcome:   e.Outcome,
+		RequestID: e.RequestID,
+		PrevHash:  a.head.Hash,
+	}
+
+	hash, err := record.hash(a.key)
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if err := a.write(line); err != nil {
+		return err
+	}
+
+	a.head = AuditHead{Seq: record.Seq, Hash: record.Hash}
+	a.size += int64(len(line))
+
+	return nil
+}
+
+// write writes and syncs a record. A partially written record would break
+// the chain of all the following ones, so the trail is truncated back on
+// failure, and closed to new records if that fails too.
+func (a *AuditLogger) write(line []byte) error {
+	_, err := a.file.Write(line)
+	if err == nil {
+		err = a.file.Sync()
+	}
+	if err == nil {
+		return nil
+	}
+
+	if terr := a.file.Truncate(a.size); terr != nil {
+		a.err = fmt.Errorf("audit trail closed after a failed write: %w, truncate: %v", err, terr)
+
+		return a.err
+	}
+
+	return err
+}
+
+// Head returns the last record written. Keep it outside of the trail and
+// pass it to NewAuditLogger or VerifyAudit to detect a truncated trail.
+func (a *AuditLogger) Head() AuditHead {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.head
+}
+
+// Close closes the audit trail file
+func (a *AuditLogger) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+
+	err := a.file.Close()
+	a.file = nil
+
+	return err
+}
+
+// hash computes the HMAC of the record without its Hash field
+func (r AuditRecord) hash(key []byte) (string, error) {
+	r.Hash = ""
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyAuditFile verifies the audit trail at path, see VerifyAudit
+func VerifyAuditFile(path string, key []byte, expected *AuditHead) (AuditHead, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return AuditHead{}, err
+	}
+	defer f.Close()
+
+	return VerifyAudit(f, key, expected)
+}
+
+// VerifyAudit checks the hash chain of the audit trail read from r with
+// the key of its records and returns its head. Modified, removed or
+// reordered records and a partially written last record break the chain.
+// Records removed from the end are only detected with expected, a head
+// kept outside of the trail such as by AuditLogger.Head, the trail must
+// then hold it.
+func VerifyAudit(r io.Reader, key []byte, expected *AuditHead) (AuditHead, error) {
+	var head AuditHead
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return head, fmt.Errorf("%w: truncated record after seq %d", ErrAuditTampered, head.Seq)
+			}
+			if expected != nil && head.Seq < expected.Seq {
+				return head, fmt.Errorf("%w: ends at seq %d before seq %d", ErrAuditTruncated, head.Seq, expected.Seq)
+			}
+
+			return head, nil
+		}
+		if err != nil {
+			return head, err
+		}
+
+		var record AuditRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return head, fmt.Errorf("%w: invalid record after seq %d: %v", ErrAuditTampered, head.Seq, err)
+		}
+
+		if record.Seq != head.Seq+1 {
+			return head, fmt.Errorf("%w: record seq %d follows seq %d", ErrAuditTampered, record.Seq, head.Seq)
+		}
+
+		if record.PrevHash != head.Hash {
+			return head, fmt.Errorf("%w: record seq %d doesn't chain to its predecessor", ErrAuditTampered, record.Seq)
+		}
+
+		hash, err := record.hash(key)
+		if err != nil {
+			return head, err
+		}
+		if !hmac.Equal([]byte(hash), []byte(record.Hash)) {
+			return head, fmt.Errorf("%w: record seq %d was modified", ErrAuditTampered, record.Seq)
+		}
+
+		if expected != nil && record.Seq == expected.Seq && record.Hash != expected.Hash {
+			return head, fmt.Errorf("%w: record seq %d is not the expected head", ErrAuditTampered, record.Seq)
+		}
+
+		head = AuditHead{Seq: record.Seq, Hash: record.Hash}
+	}
+}
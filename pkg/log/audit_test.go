@@ -0,0 +1,168 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testAuditKey = []byte("0123456789abcdef0123456789abcdef")
+
+// writeAuditTrail writes n records to a new trail and returns its path and
+// head
+func writeAuditTrail(t *testing.T, n int) (string, AuditHead) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := NewAuditLogger(path, testAuditKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	ctx := context.WithValue(context.Background(), keyRequestID, "r1")
+	for i := 0; i < n; i++ {
+		if err := a.Log(ctx, AuditEvent{Actor: "alice", Action: "delete", Resource: "user/bob", Outcome: AuditSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path, a.Head()
+}
+
+func TestAuditVerify(t *testing.T) {
+	path, head := writeAuditTrail(t, 3)
+	if head.Seq != 3 {
+		t.Fatalf("head seq %d, want 3", head.Seq)
+	}
+
+	got, err := VerifyAuditFile(path, testAuditKey, &head)
+	if err != nil || got != head {
+		t.Fatalf("verify: %v, %v, want %v", got, err, head)
+	}
+
+	// reopening appends to the chain
+	a, err := NewAuditLogger(path, testAuditKey, &head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Log(context.Background(), AuditEvent{Actor: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	if got, err := VerifyAuditFile(path, testAuditKey, &head); err != nil || got.Seq != 4 {
+		t.Fatalf("verify after reopening: %v, %v", got, err)
+	}
+}
+
+func TestAuditTampered(t *testing.T) {
+	path, head := writeAuditTrail(t, 3)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+
+	tests := []struct {
+		name     string
+		trail    []byte
+		key      []byte
+		expected *AuditHead
+		err      error
+	}{
+		{
+			name:  "modified",
+			trail: bytes.Replace(data, []byte("alice"), []byte("mallo"), 1),
+			key:   testAuditKey,
+			err:   ErrAuditTampered,
+		},
+		{
+			name:  "removed",
+			trail: bytes.Join([][]byte{lines[0], lines[2]}, nil),
+			key:   testAuditKey,
+			err:   ErrAuditTampered,
+		},
+		{
+			name:  "partial record",
+			trail: data[:len(data)-10],
+			key:   testAuditKey,
+			err:   ErrAuditTampered,
+		},
+		{
+			name:     "truncated",
+			trail:    bytes.Join(lines[:2], nil),
+			key:      testAuditKey,
+			expected: &head,
+			err:      ErrAuditTruncated,
+		},
+		{
+			name:     "other head",
+			trail:    data,
+			key:      testAuditKey,
+			expected: &AuditHead{Seq: 3, Hash: "0000"},
+			err:      ErrAuditTampered,
+		},
+		{
+			name:  "rewritten without the key",
+			trail: data,
+			key:   []byte("another key"),
+			err:   ErrAuditTampered,
+		},
+		{
+			name:  "truncated without expected head",
+			trail: bytes.Join(lines[:2], nil),
+			key:   testAuditKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifyAudit(bytes.NewReader(tt.trail), tt.key, tt.expected)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("verify: %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAuditRejectsTampered(t *testing.T) {
+	path, head := writeAuditTrail(t, 2)
+
+	if _, err := NewAuditLogger(path, []byte("another key"), nil); !errors.Is(err, ErrAuditTampered) {
+		t.Fatalf("open with another key: %v, want ErrAuditTampered", err)
+	}
+
+	head.Seq++
+	if _, err := NewAuditLogger(path, testAuditKey, &head); !errors.Is(err, ErrAuditTruncated) {
+		t.Fatalf("open with a later head: %v, want ErrAuditTruncated", err)
+	}
+
+	if _, err := NewAuditLogger(path, nil, nil); err == nil {
+		t.Fatal("opened without key")
+	}
+}
+
+func TestAuditFailedWrite(t *testing.T) {
+	path, _ := writeAuditTrail(t, 1)
+	a, err := NewAuditLogger(path, testAuditKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// neither the write nor the truncation succeed on a closed file
+	a.file.Close()
+	if err := a.Log(context.Background(), AuditEvent{Actor: "alice"}); err == nil {
+		t.Fatal("logged to a closed file")
+	}
+	if err := a.Log(context.Background(), AuditEvent{Actor: "alice"}); err == nil || err != a.err {
+		t.Fatalf("logged after a failed truncation: %v", err)
+	}
+
+	if head, err := VerifyAuditFile(path, testAuditKey, nil); err != nil || head.Seq != 1 {
+		t.Fatalf("verify: %v, %v", head, err)
+	}
+}
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// containerFiles are created by the container runtimes
+var containerFiles = []string{
+	"/var/run/secrets/kubernetes.io/serviceaccount",
+	"/.dockerenv",
+	"/run/.containerenv",
+}
+
+// stdoutTerminal reports whether stdout is a terminal
+var stdoutTerminal = func() bool {
+	return isTerminal(os.Stdout)
+}
+
+// autoFormatOnce logs the format chosen by the auto format once
+var autoFormatOnce sync.Once
+
+// resolveFormat returns the format the auto format stands for and the
+// reason of the choice: JSON in containers, whose output is collected,
+// console when stdout is a terminal and JSON otherwise.
+func resolveFormat(format string) (string, string) {
+	if strings.ToLower(format) != autoFormat {
+		return format, ""
+	}
+
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return jsonFormat, "kubernetes"
+	}
+
+	for _, path := range containerFiles {
+		if _, err := os.Stat(path); err == nil {
+			return jsonFormat, "container"
+		}
+	}
+
+	if stdoutTerminal() {
+		return consoleFormat, "terminal"
+	}
+
+	return jsonFormat, "not a terminal"
+}
+
+// logAutoFormat logs the format chosen by the auto format, once per process
+func (l *zapLogger) logAutoFormat(opts *Options, reason string) {
+	autoFormatOnce.Do(func() {
+		// the caller would be sync.Once, not the caller of New
+		l.zapLogger.WithOptions(zap.WithCaller(false)).Info("log format selected", zap.String("format", opts.Format), zap.String("reason", reason),
+			zap.Bool("color", colorEnabled(opts)))
+	})
+}
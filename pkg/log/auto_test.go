@@ -0,0 +1,68 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeEnvironment runs the test outside of Kubernetes and containers, on
+// a terminal or not
+func fakeEnvironment(t *testing.T, terminal bool) {
+	t.Helper()
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	files, isTerminal := containerFiles, stdoutTerminal
+	t.Cleanup(func() { containerFiles, stdoutTerminal = files, isTerminal })
+	containerFiles = []string{filepath.Join(t.TempDir(), "missing")}
+	stdoutTerminal = func() bool { return terminal }
+}
+
+func TestResolveFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		setup     func(t *testing.T)
+		terminal  bool
+		format    string
+		reason    string
+		requested string
+	}{
+		{
+			name:      "kubernetes",
+			setup:     func(t *testing.T) { t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1") },
+			terminal:  true,
+			format:    jsonFormat,
+			reason:    "kubernetes",
+			requested: autoFormat,
+		},
+		{
+			name: "container",
+			setup: func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), ".dockerenv")
+				if err := os.WriteFile(path, nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+				containerFiles = append(containerFiles, path)
+			},
+			terminal:  true,
+			format:    jsonFormat,
+			reason:    "container",
+			requested: "AUTO",
+		},
+		{name: "terminal", terminal: true, format: consoleFormat, reason: "terminal", requested: autoFormat},
+		{name: "not a terminal", format: jsonFormat, reason: "not a terminal", requested: autoFormat},
+		{name: "explicit", terminal: true, format: jsonFormat, requested: jsonFormat},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeEnvironment(t, tt.terminal)
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+
+			format, reason := resolveFormat(tt.requested)
+			if format != tt.format || reason != tt.reason {
+				t.Fatalf("resolved %q (%s), want %q (%s)", format, reason, tt.format, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,700 @@
+package log
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// The binary format writes each entry as a uvarint length followed by the
+// entry: the version, the time in unix nanoseconds as varint, the level,
+// the logger name, the message, the caller, the stack and the fields.
+// Strings and bytes are uvarint length prefixed. A field is its type, its
+// key and its value, arrays and objects end with binaryEnd.
+const binaryVersion = 1
+
+// Types of the values of the binary format
+const (
+	binaryEnd byte = iota
+	binaryBool
+	binaryInt
+	binaryUint
+	binaryFloat64
+	binaryFloat32
+	binaryComplex
+	binaryString
+	binaryByteString
+	binaryBinary
+	binaryDuration
+	binaryTime
+	binaryReflected
+	binaryArray
+	binaryObject
+	binaryNamespace
+)
+
+// maxBinaryEntrySize bounds the entries read, against corrupt lengths
+const maxBinaryEntrySize = 64 << 20
+
+// maxBinaryDepth bounds the nesting of the arrays and objects read,
+// against entries crafted to exhaust the stack
+const maxBinaryDepth = 64
+
+// ErrBinaryCorrupt is returned by BinaryReader for malformed entries
+var ErrBinaryCorrupt = errors.New("corrupt binary log entry")
+
+var binaryPool = buffer.NewPool()
+
+// binaryEncoder writes the fields, keyed in objects, to a buffer shared
+// with the arrays and objects it encodes
+type binaryEncoder struct {
+	buf *buffer.Buffer
+}
+
+// NewBinaryEncoder creates the encoder of the binary format, entries
+// written with it are read by BinaryReader.
+func NewBinaryEncoder() zapcore.Encoder {
+	return newPooledBinaryEncoder()
+}
+
+// newPooledBinaryEncoder returns an encoder of a pooled buffer, the buffer
+// goes back to the pool when the encoder is freed or garbage collected
+func newPooledBinaryEncoder() *binaryEncoder {
+	e := &binaryEncoder{buf: binaryPool.Get()}
+	runtime.SetFinalizer(e, (*binaryEncoder).free)
+
+	return e
+}
+
+// pooledEncoder is an encoder whose buffer can be returned to its pool
+// once the encoder is no longer used
+type pooledEncoder interface {
+	free()
+}
+
+func (e *binaryEncoder) free() {
+	runtime.SetFinalizer(e, nil)
+	if e.buf != nil {
+		e.buf.Free()
+		e.buf = nil
+	}
+}
+
+func appendUvarint(buf *buffer.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	_, _ = buf.Write(b[:n])
+}
+
+func appendVarint(buf *buffer.Buffer, v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	_, _ = buf.Write(b[:n])
+}
+
+func appendBinaryString(buf *buffer.Buffer, s string) {
+	appendUvarint(buf, uint64(len(s)))
+	buf.AppendString(s)
+}
+
+func appendBinaryBytes(buf *buffer.Buffer, b []byte) {
+	appendUvarint(buf, uint64(len(b)))
+	_, _ = buf.Write(b)
+}
+
+func appendFloat64(buf *buffer.Buffer, f float64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+	_, _ = buf.Write(b[:])
+}
+
+func (e *binaryEncoder) key(t byte, key string) {
+	e.buf.AppendByte(t)
+	appendBinaryString(e.buf, key)
+}
+
+func (e *binaryEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	e.key(binaryArray, key)
+	err := arr.MarshalLogArray(&binaryArrayEncoder{buf: e.buf})
+	e.buf.AppendByte(binaryEnd)
+
+	return err
+}
+
+func (e *binaryEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	e.key(binaryObject, key)
+	err := obj.MarshalLogObject(e)
+	e.buf.AppendByte(binaryEnd)
+
+	return err
+}
+
+func (e *binaryEncoder) AddBinary(key string, value []byte) {
+	e.key(binaryBinary, key)
+	appendBinaryBytes(e.buf, value)
+}
+
+func (e *binaryEncoder) AddByteString(key string, value []byte) {
+	e.key(binaryByteString, key)
+	appendBinaryBytes(e.buf, value)
+}
+
+func (e *binaryEncoder) AddBool(key string, value bool) {
+	e.key(binaryBool, key)
+	if value {
+		e.buf.AppendByte(1)
+	} else {
+		e.buf.AppendByte(0)
+	}
+}
+
+func (e *binaryEncoder) AddComplex128(key string, value complex128) {
+	e.key(binaryComplex, key)
+	appendFloat64(e.buf, real(value))
+	appendFloat64(e.buf, imag(value))
+}
+
+func (e *binaryEncoder) AddComplex64(key string, value complex64) {
+	e.AddComplex128(key, complex128(value))
+}
+
+func (e *binaryEncoder) AddDuration(key string, value time.Duration) {
+	e.key(binaryDuration, key)
+	appendVarint(e.buf, int64(value))
+}
+
+func (e *binaryEncoder) AddFloat64(key string, value float64) {
+	e.key(binaryFloat64, key)
+	appendFloat64(e.buf, value)
+}
+
+func (e *binaryEncoder) AddFloat32(key string, value float32) {
+	e.key(binaryFloat32, key)
+	appendFloat64(e.buf, float64(value))
+}
+
+func (e *binaryEncoder) AddInt(key string, value int)     { e.AddInt64(key, int64(value)) }
+func (e *binaryEncoder) AddInt32(key string, value int32) { e.AddInt64(key, int64(value)) }
+func (e *binaryEncoder) AddInt16(key string, value int16) { e.AddInt64(key, int64(value)) }
+func (e *binaryEncoder) AddInt8(key string, value int8)   { e.AddInt64(key, int64(value)) }
+
+func (e *binaryEncoder) AddInt64(key string, value int64) {
+	e.key(binaryInt, key)
+	appendVarint(e.buf, value)
+}
+
+func (e *binaryEncoder) AddString(key, value string) {
+	e.key(binaryString, key)
+	appendBinaryString(e.buf, value)
+}
+
+func (e *binaryEncoder) AddTime(key string, value time.Time) {
+	e.key(binaryTime, key)
+	appendVarint(e.buf, value.UnixNano())
+}
+
+func (e *binaryEncoder) AddUint(key string, value uint)       { e.AddUint64(key, uint64(value)) }
+func (e *binaryEncoder) AddUint32(key string, value uint32)   { e.AddUint64(key, uint64(value)) }
+func (e *binaryEncoder) AddUint16(key string, value uint16)   { e.AddUint64(key, uint64(value)) }
+func (e *binaryEncoder) AddUint8(key string, value uint8)     { e.AddUint64(key, uint64(value)) }
+func (e *binaryEncoder) AddUintptr(key string, value uintptr) { e.AddUint64(key, uint64(value)) }
+
+func (e *binaryEncoder) AddUint64(key string, value uint64) {
+	e.key(binaryUint, key)
+	appendUvarint(e.buf, value)
+}
+
+// AddReflected encodes the value as JSON
+func (e *binaryEncoder) AddReflected(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	e.key(binaryReflected, key)
+	appendBinaryBytes(e.buf, data)
+
+	return nil
+}
+
+// OpenNamespace opens a namespace holding the following fields of the
+// entry, it is closed at the end of the entry
+func (e *binaryEncoder) OpenNamespace(key string) {
+	e.key(binaryNamespace, key)
+}
+
+func (e *binaryEncoder) Clone() zapcore.Encoder {
+	c := newPooledBinaryEncoder()
+	_, _ = c.buf.Write(e.buf.Bytes())
+
+	return c
+}
+
+func (e *binaryEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	body := binaryPool.Get()
+	defer body.Free()
+
+	body.AppendByte(binaryVersion)
+	appendVarint(body, ent.Time.UnixNano())
+	body.AppendByte(byte(ent.Level))
+	appendBinaryString(body, ent.LoggerName)
+	appendBinaryString(body, ent.Message)
+	if ent.Caller.Defined {
+		body.AppendByte(1)
+		appendBinaryString(body, ent.Caller.File)
+		appendUvarint(body, uint64(ent.Caller.Line))
+		appendBinaryString(body, ent.Caller.Function)
+	} else {
+		body.AppendByte(0)
+	}
+	appendBinaryString(body, ent.Stack)
+
+	_, _ = body.Write(e.buf.Bytes())
+	fe := &binaryEncoder{buf: body}
+	for _, f := range fields {
+		f.AddTo(fe)
+	}
+
+	out := binaryPool.Get()
+	appendUvarint(out, uint64(body.Len()))
+	_, _ = out.Write(body.Bytes())
+
+	return out, nil
+}
+
+// binaryArrayEncoder writes the elements of an array, without keys
+type binaryArrayEncoder struct {
+	buf *buffer.Buffer
+}
+
+func (e *binaryArrayEncoder) AppendBool(v bool) {
+	e.buf.AppendByte(binaryBool)
+	if v {
+		e.buf.AppendByte(1)
+	} else {
+		e.buf.AppendByte(0)
+	}
+}
+
+func (e *binaryArrayEncoder) AppendByteString(v []byte) {
+	e.buf.AppendByte(binaryByteString)
+	appendBinaryBytes(e.buf, v)
+}
+
+func (e *binaryArrayEncoder) AppendComplex128(v complex128) {
+	e.buf.AppendByte(binaryComplex)
+	appendFloat64(e.buf, real(v))
+	appendFloat64(e.buf, imag(v))
+}
+
+func (e *binaryArrayEncoder) AppendComplex64(v complex64) { e.AppendComplex128(complex128(v)) }
+
+func (e *binaryArrayEncoder) AppendFloat64(v float64) {
+	e.buf.AppendByte(binaryFloat64)
+	appendFloat64(e.buf, v)
+}
+
+func (e *binaryArrayEncoder) AppendFloat32(v float32) {
+	e.buf.AppendByte(binaryFloat32)
+	appendFloat64(e.buf, float64(v))
+}
+
+func (e *binaryArrayEncoder) AppendInt(v int)     { e.AppendInt64(int64(v)) }
+func (e *binaryArrayEncoder) AppendInt32(v int32) { e.AppendInt64(int64(v)) }
+func (e *binaryArrayEncoder) AppendInt16(v int16) { e.AppendInt64(int64(v)) }
+func (e *binaryArrayEncoder) AppendInt8(v int8)   { e.AppendInt64(int64(v)) }
+
+func (e *binaryArrayEncoder) AppendInt64(v int64) {
+	e.buf.AppendByte(binaryInt)
+	appendVarint(e.buf, v)
+}
+
+func (e *binaryArrayEncoder) AppendString(v string) {
+	e.buf.AppendByte(binaryString)
+	appendBinaryString(e.buf, v)
+}
+
+func (e *binaryArrayEncoder) AppendUint(v uint)       { e.AppendUint64(uint64(v)) }
+func (e *binaryArrayEncoder) AppendUint32(v uint32)   { e.AppendUint64(uint64(v)) }
+func (e *binaryArrayEncoder) AppendUint16(v uint16)   { e.AppendUint64(uint64(v)) }
+func (e *binaryArrayEncoder) AppendUint8(v uint8)     { e.AppendUint64(uint64(v)) }
+func (e *binaryArrayEncoder) AppendUintptr(v uintptr) { e.AppendUint64(uint64(v)) }
+
+func (e *binaryArrayEncoder) AppendUint64(v uint64) {
+	e.buf.AppendByte(binaryUint)
+	appendUvarint(e.buf, v)
+}
+
+func (e *binaryArrayEncoder) AppendDuration(v time.Duration) {
+	e.buf.AppendByte(binaryDuration)
+	appendVarint(e.buf, int64(v))
+}
+
+func (e *binaryArrayEncoder) AppendTime(v time.Time) {
+	e.buf.AppendByte(binaryTime)
+	appendVarint(e.buf, v.UnixNano())
+}
+
+func (e *binaryArrayEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	e.buf.AppendByte(binaryArray)
+	err := arr.MarshalLogArray(e)
+	e.buf.AppendByte(binaryEnd)
+
+	return err
+}
+
+func (e *binaryArrayEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	e.buf.AppendByte(binaryObject)
+	err := obj.MarshalLogObject(&binaryEncoder{buf: e.buf})
+	e.buf.AppendByte(binaryEnd)
+
+	return err
+}
+
+func (e *binaryArrayEncoder) AppendReflected(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.buf.AppendByte(binaryReflected)
+	appendBinaryBytes(e.buf, data)
+
+	return nil
+}
+
+// BinaryReader reads the entries written by the binary encoder.
+type BinaryReader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewBinaryReader creates a reader of the entries of r
+func NewBinaryReader(r io.Reader) *BinaryReader {
+	return &BinaryReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next entry and its fields, which can be encoded again
+// with any zap encoder. It returns io.EOF at the end of the input.
+func (r *BinaryReader) Next() (zapcore.Entry, []zapcore.Field, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return zapcore.Entry{}, nil, io.EOF
+		}
+
+		return zapcore.Entry{}, nil, fmt.Errorf("%w: %v", ErrBinaryCorrupt, err)
+	}
+
+	if size > maxBinaryEntrySize {
+		return zapcore.Entry{}, nil, fmt.Errorf("%w: entry of %d bytes", ErrBinaryCorrupt, size)
+	}
+
+	if uint64(cap(r.buf)) < size {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		return zapcore.Entry{}, nil, fmt.Errorf("%w: %v", ErrBinaryCorrupt, err)
+	}
+
+	// the strings of the entry are copied out of the buffer, it is reused
+	d := &binaryDecoder{b: r.buf}
+	ent, fields := d.entry()
+	if d.err != nil {
+		return zapcore.Entry{}, nil, d.err
+	}
+
+	return ent, fields, nil
+}
+
+// binaryDecoder decodes an entry, the first error stops the decoding
+type binaryDecoder struct {
+	b     []byte
+	err   error
+	depth int
+}
+
+func (d *binaryDecoder) fail() {
+	if d.err == nil {
+		d.err = ErrBinaryCorrupt
+	}
+	d.b = nil
+}
+
+func (d *binaryDecoder) byte() byte {
+	if len(d.b) < 1 {
+		d.fail()
+
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+
+	return c
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail()
+
+		return 0
+	}
+	d.b = d.b[n:]
+
+	return v
+}
+
+func (d *binaryDecoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.fail()
+
+		return 0
+	}
+	d.b = d.b[n:]
+
+	return v
+}
+
+func (d *binaryDecoder) float64() float64 {
+	if len(d.b) < 8 {
+		d.fail()
+
+		return 0
+	}
+	f := math.Float64frombits(binary.LittleEndian.Uint64(d.b))
+	d.b = d.b[8:]
+
+	return f
+}
+
+func (d *binaryDecoder) bytes() []byte {
+	n := d.uvarint()
+	if uint64(len(d.b)) < n {
+		d.fail()
+
+		return nil
+	}
+	b := append([]byte(nil), d.b[:n]...)
+	d.b = d.b[n:]
+
+	return b
+}
+
+func (d *binaryDecoder) string() string {
+	n := d.uvarint()
+	if uint64(len(d.b)) < n {
+		d.fail()
+
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+
+	return s
+}
+
+func (d *binaryDecoder) entry() (zapcore.Entry, []zapcore.Field) {
+	var ent zapcore.Entry
+	if version := d.byte(); version != binaryVersion {
+		d.err = fmt.Errorf("%w: unknown version %d", ErrBinaryCorrupt, version)
+
+		return ent, nil
+	}
+
+	ent.Time = time.Unix(0, d.varint())
+	ent.Level = zapcore.Level(int8(d.byte()))
+	ent.LoggerName = d.string()
+	ent.Message = d.string()
+	if d.byte() == 1 {
+		ent.Caller.Defined = true
+		ent.Caller.File = d.string()
+		ent.Caller.Line = int(d.uvarint())
+		ent.Caller.Function = d.string()
+	}
+	ent.Stack = d.string()
+
+	var fields []zapcore.Field
+	for len(d.b) > 0 {
+		t := d.byte()
+		key := d.string()
+		fields = append(fields, d.value(t).field(key))
+	}
+
+	return ent, fields
+}
+
+// values reads values, keyed or not, until binaryEnd
+func (d *binaryDecoder) values(keyed bool) []binaryValue {
+	if d.depth++; d.depth > maxBinaryDepth {
+		d.err = fmt.Errorf("%w: nested deeper than %d", ErrBinaryCorrupt, maxBinaryDepth)
+		d.b = nil
+
+		return nil
+	}
+	defer func() { d.depth-- }()
+
+	var values []binaryValue
+	for d.err == nil {
+		t := d.byte()
+		if t == binaryEnd {
+			break
+		}
+
+		var key string
+		if keyed {
+			key = d.string()
+		}
+		v := d.value(t)
+		v.key = key
+		values = append(values, v)
+	}
+
+	return values
+}
+
+func (d *binaryDecoder) value(t byte) binaryValue {
+	v := binaryValue{t: t}
+	switch t {
+	case binaryBool:
+		v.i = int64(d.byte())
+	case binaryInt, binaryDuration, binaryTime:
+		v.i = d.varint()
+	case binaryUint:
+		v.u = d.uvarint()
+	case binaryFloat64, binaryFloat32:
+		v.f = d.float64()
+	case binaryComplex:
+		v.c = complex(d.float64(), d.float64())
+	case binaryString:
+		v.s = d.string()
+	case binaryByteString, binaryBinary, binaryReflected:
+		v.b = d.bytes()
+	case binaryArray:
+		v.values = d.values(false)
+	case binaryObject:
+		v.values = d.values(true)
+	case binaryNamespace:
+	default:
+		d.err = fmt.Errorf("%w: unknown type %d", ErrBinaryCorrupt, t)
+		d.b = nil
+	}
+
+	return v
+}
+
+// binaryValue is a decoded value, the elements of an array or the fields
+// of an object are in values
+type binaryValue struct {
+	t      byte
+	key    string
+	i      int64
+	u      uint64
+	f      float64
+	c      complex128
+	s      string
+	b      []byte
+	values []binaryValue
+}
+
+func (v binaryValue) field(key string) zapcore.Field {
+	switch v.t {
+	case binaryBool:
+		return zap.Bool(key, v.i == 1)
+	case binaryInt:
+		return zap.Int64(key, v.i)
+	case binaryUint:
+		return zap.Uint64(key, v.u)
+	case binaryFloat64:
+		return zap.Float64(key, v.f)
+	case binaryFloat32:
+		return zap.Float32(key, float32(v.f))
+	case binaryComplex:
+		return zap.Complex128(key, v.c)
+	case binaryString:
+		return zap.String(key, v.s)
+	case binaryByteString:
+		return zap.ByteString(key, v.b)
+	case binaryBinary:
+		return zap.Binary(key, v.b)
+	case binaryDuration:
+		return zap.Duration(key, time.Duration(v.i))
+	case binaryTime:
+		return zap.Time(key, time.Unix(0, v.i))
+	case binaryReflected:
+		return zap.Reflect(key, json.RawMessage(v.b))
+	case binaryArray:
+		return zap.Array(key, binaryArrayValue(v.values))
+	case binaryObject:
+		return zap.Object(key, binaryObjectValue(v.values))
+	case binaryNamespace:
+		return zap.Namespace(key)
+	}
+
+	return zap.Skip()
+}
+
+func (v binaryValue) appendTo(enc zapcore.ArrayEncoder) error {
+	switch v.t {
+	case binaryBool:
+		enc.AppendBool(v.i == 1)
+	case binaryInt:
+		enc.AppendInt64(v.i)
+	case binaryUint:
+		enc.AppendUint64(v.u)
+	case binaryFloat64:
+		enc.AppendFloat64(v.f)
+	case binaryFloat32:
+		enc.AppendFloat32(float32(v.f))
+	case binaryComplex:
+		enc.AppendComplex128(v.c)
+	case binaryString:
+		enc.AppendString(v.s)
+	case binaryByteString, binaryBinary:
+		enc.AppendByteString(v.b)
+	case binaryDuration:
+		enc.AppendDuration(time.Duration(v.i))
+	case binaryTime:
+		enc.AppendTime(time.Unix(0, v.i))
+	case binaryReflected:
+		return enc.AppendReflected(json.RawMessage(v.b))
+	case binaryArray:
+		return enc.AppendArray(binaryArrayValue(v.values))
+	case binaryObject:
+		return enc.AppendObject(binaryObjectValue(v.values))
+	}
+
+	return nil
+}
+
+type binaryArrayValue []binaryValue
+
+func (a binaryArrayValue) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, v := range a {
+		if err := v.appendTo(enc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type binaryObjectValue []binaryValue
+
+func (o binaryObjectValue) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, v := range o {
+		v.field(v.key).AddTo(enc)
+	}
+
+	return nil
+}
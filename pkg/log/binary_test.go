@@ -0,0 +1,234 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type binaryUser struct {
+	Name  string
+	Roles []string
+}
+
+func (u binaryUser) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", u.Name)
+
+	return enc.AddArray("roles", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, r := range u.Roles {
+			arr.AppendString(r)
+		}
+
+		return nil
+	}))
+}
+
+// binaryTestFields has a field of every type of the binary format
+var binaryTestFields = []zapcore.Field{
+	zap.Bool("bool", true),
+	zap.Int("int", -42),
+	zap.Uint64("uint", 1<<63),
+	zap.Float64("float64", 3.25),
+	zap.Float32("float32", 1.5),
+	zap.Complex128("complex", complex(1, -2)),
+	zap.String("string", "héllo"),
+	zap.ByteString("bytestring", []byte("raw")),
+	zap.Binary("binary", []byte{0, 1, 2}),
+	zap.Duration("duration", 1500*time.Millisecond),
+	zap.Time("time", time.Unix(1700000000, 123)),
+	zap.Reflect("reflected", map[string]int{"a": 1}),
+	zap.Ints("ints", []int{1, 2, 3}),
+	zap.Object("user", binaryUser{Name: "bob", Roles: []string{"admin", "dev"}}),
+	zap.Namespace("ns"),
+	zap.String("nested", "value"),
+}
+
+var binaryTestEntry = zapcore.Entry{
+	Level:      zapcore.WarnLevel,
+	Time:       time.Unix(1700000000, 456),
+	LoggerName: "test",
+	Message:    "binary entry",
+	Caller:     zapcore.NewEntryCaller(0, "pkg/log/binary_test.go", 42, true),
+	Stack:      "goroutine 1 [running]:",
+}
+
+func binaryTestJSONEncoder() zapcore.Encoder {
+	cfg := zap.NewProductionEncoderConfig()
+	cfg.EncodeTime = zapcore.EpochNanosTimeEncoder
+	cfg.FunctionKey = "function"
+
+	return zapcore.NewJSONEncoder(cfg)
+}
+
+// encodeJSON encodes the entry with the context fields and fields as JSON
+func encodeJSON(t testing.TB, ent zapcore.Entry, context, fields []zapcore.Field) string {
+	t.Helper()
+
+	enc := binaryTestJSONEncoder()
+	for _, f := range context {
+		f.AddTo(enc)
+	}
+	buf, err := enc.EncodeEntry(ent, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buf.Free()
+
+	return buf.String()
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	context := []zapcore.Field{zap.String("requestID", "r1")}
+	base := NewBinaryEncoder()
+	enc := base.Clone()
+	for _, f := range context {
+		f.AddTo(enc)
+	}
+
+	var out bytes.Buffer
+	for i := 0; i < 2; i++ {
+		buf, err := enc.EncodeEntry(binaryTestEntry, binaryTestFields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Write(buf.Bytes())
+		buf.Free()
+	}
+
+	want := encodeJSON(t, binaryTestEntry, context, binaryTestFields)
+	r := NewBinaryReader(&out)
+	for i := 0; i < 2; i++ {
+		ent, fields, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := encodeJSON(t, ent, nil, fields); got != want {
+			t.Fatalf("entry %d read as\n%s\nwant\n%s", i, got, want)
+		}
+	}
+	if _, _, err := r.Next(); err != io.EOF {
+		t.Fatalf("read %v at the end, want io.EOF", err)
+	}
+}
+
+func TestBinaryCloneIsolation(t *testing.T) {
+	base := NewBinaryEncoder()
+	base.AddString("service", "api")
+	clone := base.Clone()
+	clone.AddString("requestID", "r1")
+
+	// the clone of a single entry is freed, the base keeps its fields
+	buf, err := newLimitEncoder(base, &sizeLimits{field: 64}, "message").EncodeEntry(binaryTestEntry, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fields, err := NewBinaryReader(bytes.NewReader(buf.Bytes())).Next()
+	buf.Free()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 || fields[0].Key != "service" {
+		t.Fatalf("fields %v, want the fields of the base only", fields)
+	}
+	if clone.(*binaryEncoder).buf == nil {
+		t.Fatal("buffer of a live clone freed")
+	}
+}
+
+// nestedArray nests arrays depth times
+type nestedArray int
+
+func (n nestedArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	if n > 1 {
+		return enc.AppendArray(n - 1)
+	}
+
+	return nil
+}
+
+func TestBinaryReaderDepth(t *testing.T) {
+	encode := func(depth int) []byte {
+		buf, err := NewBinaryEncoder().EncodeEntry(binaryTestEntry, []zapcore.Field{zap.Array("nested", nestedArray(depth))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer buf.Free()
+
+		return append([]byte(nil), buf.Bytes()...)
+	}
+
+	if _, _, err := NewBinaryReader(bytes.NewReader(encode(maxBinaryDepth))).Next(); err != nil {
+		t.Fatalf("entry nested %d deep: %v", maxBinaryDepth, err)
+	}
+	if _, _, err := NewBinaryReader(bytes.NewReader(encode(100000))).Next(); !errors.Is(err, ErrBinaryCorrupt) {
+		t.Fatalf("read %v, want ErrBinaryCorrupt", err)
+	}
+}
+
+func TestBinaryReaderCorrupt(t *testing.T) {
+	buf, err := NewBinaryEncoder().EncodeEntry(binaryTestEntry, []zapcore.Field{zap.Bool("b", true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append([]byte(nil), buf.Bytes()...)
+	buf.Free()
+
+	// a truncated entry
+	if _, _, err := NewBinaryReader(bytes.NewReader(data[:len(data)-3])).Next(); !errors.Is(err, ErrBinaryCorrupt) {
+		t.Fatalf("read %v, want ErrBinaryCorrupt", err)
+	}
+
+	// an unknown type in place of the type of the field, followed by its
+	// key and its value
+	data[len(data)-4] = 0xff
+	if _, _, err := NewBinaryReader(bytes.NewReader(data)).Next(); !errors.Is(err, ErrBinaryCorrupt) {
+		t.Fatalf("read %v, want ErrBinaryCorrupt", err)
+	}
+}
+
+func benchmarkEncoder(b *testing.B, enc zapcore.Encoder) {
+	enc = enc.Clone()
+	enc.AddString("requestID", "r1")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, err := enc.EncodeEntry(binaryTestEntry, binaryTestFields)
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf.Free()
+	}
+}
+
+func BenchmarkBinaryEncoder(b *testing.B) { benchmarkEncoder(b, NewBinaryEncoder()) }
+func BenchmarkJSONEncoder(b *testing.B)   { benchmarkEncoder(b, binaryTestJSONEncoder()) }
+
+func BenchmarkBinaryReader(b *testing.B) {
+	buf, err := NewBinaryEncoder().EncodeEntry(binaryTestEntry, binaryTestFields)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := bytes.Repeat(buf.Bytes(), 64)
+	buf.Free()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := NewBinaryReader(bytes.NewReader(data))
+		for {
+			if _, _, err := r.Next(); err != nil {
+				if err != io.EOF {
+					b.Fatal(err)
+				}
+
+				break
+			}
+		}
+	}
+}
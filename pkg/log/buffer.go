@@ -0,0 +1,310 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// bufferMaxLevel is the highest level of the entries buffered, the
+	// entries between it and bufferFlushLevel are written right away
+	bufferMaxLevel = InfoLevel
+	// bufferFlushLevel is the level of the entries which write out the
+	// buffered entries of a request
+	bufferFlushLevel = ErrorLevel
+)
+
+// bufferedEntry is an entry held in memory with the core to write it to
+type bufferedEntry struct {
+	core   zapcore.Core
+	ent    zapcore.Entry
+	fields []zapcore.Field
+	size   int
+}
+
+// requestBuffer holds the entries of a request up to bufferMaxLevel in
+// memory, until the request logs at bufferFlushLevel or finishes.
+type requestBuffer struct {
+	mu         sync.Mutex
+	entries    []bufferedEntry
+	size       int
+	maxEntries int
+	maxBytes   int
+	dropped    int
+	// triggered is set once an entry at bufferFlushLevel is logged
+	triggered bool
+	// finished is set when the request ends
+	finished bool
+}
+
+// buffering reports whether entries are still held in memory
+func (b *requestBuffer) buffering() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !b.triggered && !b.finished
+}
+
+// core returns the core the entries are written to once not buffering.
+// A request which logged an error keeps logging at debug level.
+func (b *requestBuffer) core(c *bufferCore) zapcore.Core {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.triggered && !b.finished {
+		return c.elevated
+	}
+
+	return c.Core
+}
+
+func (b *requestBuffer) add(e bufferedEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.triggered || b.finished {
+		// the entry was checked before the state changed
+		if b.triggered {
+			writeEntry(e)
+		}
+
+		return
+	}
+
+	b.entries = append(b.entries, e)
+	b.size += e.size
+
+	// drop the oldest entries beyond the limits
+	drop := 0
+	for len(b.entries)-drop > 0 &&
+		((b.maxEntries > 0 && len(b.entries)-drop > b.maxEntries) || (b.maxBytes > 0 && b.size > b.maxBytes)) {
+		b.size -= b.entries[drop].size
+		drop++
+	}
+	if drop > 0 {
+		b.dropped += drop
+		b.entries = append(b.entries[:0], b.entries[drop:]...)
+	}
+}
+
+// flush writes out the buffered entries, later entries are not buffered
+func (b *requestBuffer) flush(core zapcore.Core) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.triggered || b.finished {
+		return
+	}
+	b.triggered = true
+
+	if b.dropped > 0 {
+		if ce := core.Check(zapcore.Entry{
+			Level:   zapcore.WarnLevel,
+			Time:    time.Now(),
+			Message: "buffered log entries dropped over the request buffer limits",
+		}, nil); ce != nil {
+			ce.Write(zap.Int("dropped", b.dropped))
+		}
+	}
+
+	for _, e := range b.entries {
+		writeEntry(e)
+	}
+	b.entries = nil
+	b.size = 0
+}
+
+// discard drops the buffered entries at the end of the request
+func (b *requestBuffer) discard() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.finished = true
+	b.entries = nil
+	b.size = 0
+}
+
+func writeEntry(e bufferedEntry) {
+	if ce := e.core.Check(e.ent, nil); ce != nil {
+		ce.Write(e.fields...)
+	}
+}
+
+// entrySize estimates the memory held by a buffered entry
+func entrySize(ent zapcore.Entry, fields []zapcore.Field) int {
+	size := 128 + len(ent.Message) + len(ent.LoggerName) + len(ent.Stack)
+	for _, f := range fields {
+		size += 64 + len(f.Key) + len(f.String)
+	}
+
+	return size
+}
+
+// bufferCore holds the entries up to bufferMaxLevel of a request in a
+// requestBuffer, entries at bufferFlushLevel write them out first. The
+// entries in between, such as warnings, are written right away, before
+// the buffered entries logged earlier.
+type bufferCore struct {
+	zapcore.Core
+	// elevated is the core enabled at debug level the entries are written to
+	elevated zapcore.Core
+	buf      *requestBuffer
+}
+
+func newBufferCore(core zapcore.Core, buf *requestBuffer) *bufferCore {
+	return &bufferCore{
+		Core:     core,
+		elevated: elevateCore(core),
+		buf:      buf,
+	}
+}
+
+func (c *bufferCore) Enabled(lvl zapcore.Level) bool {
+	if c.buf.buffering() {
+		return c.elevated.Enabled(lvl)
+	}
+
+	return c.buf.core(c).Enabled(lvl)
+}
+
+func (c *bufferCore) With(fields []zapcore.Field) zapcore.Core {
+	return &bufferCore{
+		Core:     c.Core.With(fields),
+		elevated: c.elevated.With(fields),
+		buf:      c.buf,
+	}
+}
+
+func (c *bufferCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.buf.buffering() {
+		return c.buf.core(c).Check(ent, ce)
+	}
+
+	if levelRank(ent.Level) >= levelRank(bufferFlushLevel) {
+		c.buf.flush(c.Core)
+
+		return c.Core.Check(ent, ce)
+	}
+
+	if levelRank(ent.Level) > levelRank(bufferMaxLevel) {
+		return c.Core.Check(ent, ce)
+	}
+
+	// the stack trace is the one of the caller, not of the flush
+	if c.elevated.Enabled(ent.Level) {
+		return ce.AddCore(recordStack(c.elevated, ent), c)
+	}
+
+	return ce
+}
+
+func (c *bufferCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	fields = freezeFields(fields)
+	c.buf.add(bufferedEntry{
+		core:   c.elevated,
+		ent:    ent,
+		fields: fields,
+		size:   entrySize(ent, fields),
+	})
+
+	return nil
+}
+
+// freezeFields copies the fields of a buffered entry with the values they
+// refer to, which may change before the entry is written. The objects,
+// arrays and reflected values are kept as JSON.
+func freezeFields(fields []zapcore.Field) []zapcore.Field {
+	frozen := make([]zapcore.Field, 0, len(fields))
+	for _, f := range fields {
+		switch f.Type {
+		case zapcore.BinaryType:
+			frozen = append(frozen, zap.Binary(f.Key, append([]byte(nil), f.Interface.([]byte)...)))
+		case zapcore.ByteStringType:
+			frozen = append(frozen, zap.ByteString(f.Key, append([]byte(nil), f.Interface.([]byte)...)))
+		case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType,
+			zapcore.ReflectType, zapcore.StringerType, zapcore.ErrorType:
+			enc := zapcore.NewMapObjectEncoder()
+			f.AddTo(enc)
+
+			// an error or an inline object may add several fields
+			keys := make([]string, 0, len(enc.Fields))
+			for key := range enc.Fields {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				frozen = append(frozen, frozenField(key, enc.Fields[key]))
+			}
+		default:
+			frozen = append(frozen, f)
+		}
+	}
+
+	return frozen
+}
+
+// frozenField returns a field of a value captured by a map encoder, a
+// copy of the scalars and JSON otherwise
+func frozenField(key string, value interface{}) zapcore.Field {
+	f := zap.Any(key, value)
+	switch f.Type {
+	case zapcore.BinaryType:
+		return zap.Binary(key, append([]byte(nil), value.([]byte)...))
+	case zapcore.ReflectType:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return zap.String(key, fmt.Sprintf("%+v", value))
+		}
+
+		return zap.Reflect(key, json.RawMessage(data))
+	}
+
+	return f
+}
+
+// buffered returns a child logger holding its entries up to info level in
+// memory, see WithBuffering
+func (l *zapLogger) buffered() (*zapLogger, *requestBuffer) {
+	buf := &requestBuffer{
+		maxEntries: l.bufferLimits.maxEntries,
+		maxBytes:   l.bufferLimits.maxBytes,
+	}
+
+	lg := l.derive(l.zapLogger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return newBufferCore(c, buf)
+	})))
+
+	return lg, buf
+}
+
+// WithBuffering stores a child of the logger of ctx, or of the default
+// logger, in ctx for FromContext. The entries of the child up to info
+// level, debug ones included, are held in memory and only written out once
+// the request logs at error level or above, the notice and warn entries
+// are written right away. The returned function discards them at the end
+// of the request:
+//
+//	ctx, done := log.WithBuffering(ctx)
+//	defer done()
+func WithBuffering(ctx context.Context) (context.Context, func()) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	logger, ok := ctx.Value(logContextKey).(*zapLogger)
+	if !ok {
+		logger = std
+	}
+
+	bl, buf := logger.buffered()
+
+	return bl.WithContext(ctx), buf.discard
+}
@@ -0,0 +1,153 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func bufferedLogger(t *testing.T, opts *Options) (Logger, func(), func() []map[string]interface{}) {
+	t.Helper()
+
+	if opts == nil {
+		opts = newTestOptions(t)
+		opts.Level = "info"
+	}
+	l, entries := newTestLogger(t, opts)
+	ctx, done := WithBuffering(l.WithContext(context.Background()))
+
+	return FromContext(ctx), done, entries
+}
+
+func entryMessages(entries []map[string]interface{}) []string {
+	msgs := make([]string, len(entries))
+	for i, entry := range entries {
+		msgs[i], _ = entry["message"].(string)
+	}
+
+	return msgs
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestBufferSuccessfulRequest(t *testing.T) {
+	l, done, entries := bufferedLogger(t, nil)
+
+	l.Debug("debug")
+	l.Info("info")
+	l.Notice("notice")
+	l.Warn("warn")
+	done()
+
+	// the entries above info are not held back
+	if got := entryMessages(entries()); !equalStrings(got, []string{"notice", "warn"}) {
+		t.Fatalf("entries %v, want [notice warn]", got)
+	}
+
+	l.Info("after")
+	if got := entryMessages(entries()); len(got) != 3 || got[2] != "after" {
+		t.Fatalf("entries %v, want the entry after the request", got)
+	}
+}
+
+func TestBufferFailedRequest(t *testing.T) {
+	l, done, entries := bufferedLogger(t, nil)
+	defer done()
+
+	l.Trace("trace")
+	l.Debug("debug")
+	l.Warn("warn")
+	l.Info("info")
+	l.Error("error")
+	l.Debug("later")
+
+	// trace is below debug, the level the buffered entries are written at
+	want := []string{"warn", "debug", "info", "error", "later"}
+	if got := entryMessages(entries()); !equalStrings(got, want) {
+		t.Fatalf("entries %v, want %v", got, want)
+	}
+}
+
+type mutable struct {
+	name string
+}
+
+func (m *mutable) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", m.name)
+
+	return nil
+}
+
+func TestBufferFreezesFields(t *testing.T) {
+	l, done, entries := bufferedLogger(t, nil)
+	defer done()
+
+	items := []string{"a", "b"}
+	obj := &mutable{name: "before"}
+	data := []byte("before")
+	l.Info("buffered", zap.Any("items", items), zap.Object("obj", obj), zap.Binary("data", data),
+		zap.Stringer("stringer", InfoLevel), zap.Error(errors.New("failed")))
+
+	items[0] = "changed"
+	obj.name = "after"
+	copy(data, "after!")
+	l.Error("error")
+
+	got := entries()
+	if len(got) != 2 {
+		t.Fatalf("%d entries, want 2", len(got))
+	}
+	if items := got[0]["items"].([]interface{}); items[0] != "a" {
+		t.Fatalf("items %v modified after buffering", items)
+	}
+	if obj := got[0]["obj"].(map[string]interface{}); obj["name"] != "before" {
+		t.Fatalf("object %v modified after buffering", obj)
+	}
+	if got[0]["data"] != "YmVmb3Jl" || got[0]["error"] != "failed" {
+		t.Fatalf("entry %v", got[0])
+	}
+}
+
+func TestBufferLimits(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.Level = "info"
+	opts.RequestBufferMaxEntries = 2
+	l, done, entries := bufferedLogger(t, opts)
+	defer done()
+
+	for _, msg := range []string{"1", "2", "3"} {
+		l.Info(msg)
+	}
+	l.Error("error")
+
+	got := entries()
+	if !equalStrings(entryMessages(got), []string{"buffered log entries dropped over the request buffer limits", "2", "3", "error"}) {
+		t.Fatalf("entries %v", entryMessages(got))
+	}
+	if got[0]["dropped"] != float64(1) {
+		t.Fatalf("dropped %v, want 1", got[0]["dropped"])
+	}
+}
+
+func TestBufferNilContext(t *testing.T) {
+	ctx, done := WithBuffering(nil)
+	defer done()
+
+	if ctx == nil {
+		t.Fatal("nil context")
+	}
+}
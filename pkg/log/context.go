@@ -8,6 +8,7 @@ type key int
 
 const (
 	logContextKey key = iota
+	debugContextKey
 )
 
 func WithContext(ctx context.Context) context.Context {
@@ -18,11 +19,15 @@ func (l *zapLogger) WithContext(ctx context.Context) context.Context {
 	return context.WithValue(ctx, logContextKey, l)
 }
 
+// FromContext returns the logger stored in ctx by WithContext.
 func FromContext(ctx context.Context) Logger {
 	if ctx != nil {
-		logger := ctx.Value(ctx)
-		if logger != nil {
-			return logger.(Logger)
+		if logger, ok := ctx.Value(logContextKey).(Logger); ok {
+			if zl, ok := logger.(*zapLogger); ok && DebugElevated(ctx) {
+				return zl.elevate()
+			}
+
+			return logger
 		}
 	}
 
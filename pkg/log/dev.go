@@ -0,0 +1,490 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
+)
+
+// Layout of the development console
+const (
+	devCallerWidth  = 24
+	devMessageWidth = 40
+	devIndent       = "    "
+)
+
+// ANSI styles of the development console
+const (
+	devReset  = "\x1b[0m"
+	devBold   = "\x1b[1m"
+	devDim    = "\x1b[2m"
+	devKey    = "\x1b[2;36m" // dimmed cyan
+	devModule = "\x1b[1;33m" // bold yellow
+)
+
+var devPool = buffer.NewPool()
+
+// devField is a field materialized by the development encoder, the
+// objects and arrays as maps and slices
+type devField struct {
+	key       string
+	value     interface{}
+	namespace bool
+}
+
+// devEncoder renders the entries for the console in development: aligned
+// columns, colored keys, objects and arrays as indented JSON, durations
+// as text and stack traces one frame per line, the frames of the main
+// module highlighted.
+type devEncoder struct {
+	color  bool
+	module string
+	// width returns the width of the terminal, 0 if unknown
+	width  func() int
+	fields []devField
+}
+
+// newDevEncoder creates the encoder of the console, out is the terminal of
+// the output paths, nil if they aren't one
+func newDevEncoder(color bool, out *os.File) *devEncoder {
+	var module string
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		module = bi.Main.Path
+	}
+
+	return &devEncoder{color: color, module: module, width: widthOf(out)}
+}
+
+// colorEnabled reports whether the console entries are colored, only when
+// every output path is a terminal
+func colorEnabled(opts *Options) bool {
+	return strings.ToLower(opts.Format) == consoleFormat && opts.EnableColor && outputTerminal(opts) != nil
+}
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// outputTerminal returns the terminal the output paths write to, nil if
+// one of them isn't stdout or stderr on a terminal
+func outputTerminal(opts *Options) *os.File {
+	var out *os.File
+	for _, path := range opts.OutputPaths {
+		var f *os.File
+		switch path {
+		case "stdout":
+			f = os.Stdout
+		case "stderr":
+			f = os.Stderr
+		default:
+			return nil
+		}
+
+		if !isTerminal(f) {
+			return nil
+		}
+		if out == nil {
+			out = f
+		}
+	}
+
+	return out
+}
+
+// terminalWidths holds the widths of the terminals, by file, updated when
+// the terminals are resized
+var terminalWidths sync.Map
+
+// widthOf returns a function returning the width of the terminal f, the
+// width is computed once and again when the terminal is resized. Without
+// a terminal the width is COLUMNS, 0 if unknown.
+func widthOf(f *os.File) func() int {
+	if f == nil {
+		width, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+
+		return func() int { return width }
+	}
+
+	w := &terminalWidth{f: f}
+	if v, loaded := terminalWidths.LoadOrStore(f, w); loaded {
+		w = v.(*terminalWidth)
+	} else {
+		w.update()
+		watchResize(w.update)
+	}
+
+	return w.get
+}
+
+// terminalWidth is the width of a terminal
+type terminalWidth struct {
+	f     *os.File
+	width int64
+}
+
+func (w *terminalWidth) update() {
+	width, _, err := term.GetSize(int(w.f.Fd()))
+	if err != nil {
+		width = 0
+	}
+	atomic.StoreInt64(&w.width, int64(width))
+}
+
+func (w *terminalWidth) get() int {
+	return int(atomic.LoadInt64(&w.width))
+}
+
+func (e *devEncoder) add(key string, value interface{}) {
+	e.fields = append(e.fields, devField{key: key, value: value})
+}
+
+func (e *devEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	err := m.AddArray(key, arr)
+	e.add(key, m.Fields[key])
+
+	return err
+}
+
+func (e *devEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	err := obj.MarshalLogObject(m)
+	e.add(key, m.Fields)
+
+	return err
+}
+
+func (e *devEncoder) AddBinary(key string, value []byte) {
+	e.add(key, append([]byte(nil), value...))
+}
+
+func (e *devEncoder) AddByteString(key string, value []byte)      { e.add(key, string(value)) }
+func (e *devEncoder) AddBool(key string, value bool)              { e.add(key, value) }
+func (e *devEncoder) AddComplex128(key string, value complex128)  { e.add(key, value) }
+func (e *devEncoder) AddComplex64(key string, value complex64)    { e.add(key, value) }
+func (e *devEncoder) AddDuration(key string, value time.Duration) { e.add(key, value) }
+func (e *devEncoder) AddFloat64(key string, value float64)        { e.add(key, value) }
+func (e *devEncoder) AddFloat32(key string, value float32)        { e.add(key, value) }
+func (e *devEncoder) AddInt(key string, value int)                { e.add(key, value) }
+func (e *devEncoder) AddInt64(key string, value int64)            { e.add(key, value) }
+func (e *devEncoder) AddInt32(key string, value int32)            { e.add(key, value) }
+func (e *devEncoder) AddInt16(key string, value int16)            { e.add(key, value) }
+func (e *devEncoder) AddInt8(key string, value int8)              { e.add(key, value) }
+func (e *devEncoder) AddString(key, value string)                 { e.add(key, value) }
+func (e *devEncoder) AddTime(key string, value time.Time)         { e.add(key, value) }
+func (e *devEncoder) AddUint(key string, value uint)              { e.add(key, value) }
+func (e *devEncoder) AddUint64(key string, value uint64)          { e.add(key, value) }
+func (e *devEncoder) AddUint32(key string, value uint32)          { e.add(key, value) }
+func (e *devEncoder) AddUint16(key string, value uint16)          { e.add(key, value) }
+func (e *devEncoder) AddUint8(key string, value uint8)            { e.add(key, value) }
+func (e *devEncoder) AddUintptr(key string, value uintptr)        { e.add(key, value) }
+
+// AddReflected keeps the value as JSON, it may change after the call
+func (e *devEncoder) AddReflected(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	e.add(key, json.RawMessage(data))
+
+	return nil
+}
+
+// OpenNamespace prefixes the keys of the following fields with key
+func (e *devEncoder) OpenNamespace(key string) {
+	e.fields = append(e.fields, devField{key: key, namespace: true})
+}
+
+func (e *devEncoder) Clone() zapcore.Encoder {
+	return &devEncoder{
+		color:  e.color,
+		module: e.module,
+		width:  e.width,
+		fields: append([]devField(nil), e.fields...),
+	}
+}
+
+func (e *devEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	c := e.Clone().(*devEncoder)
+	for _, f := range fields {
+		f.AddTo(c)
+	}
+
+	buf := devPool.Get()
+	width := 0
+
+	// the columns, padded on their visible width
+	e.style(buf, devDim, ent.Time.Format(TimeLayout))
+	width += len(TimeLayout)
+
+	level := fmt.Sprintf("%-6s", strings.ToUpper(LevelString(ent.Level)))
+	buf.AppendByte(' ')
+	if color, ok := levelColors[ent.Level]; ok && e.color {
+		buf.AppendString(fmt.Sprintf("\x1b[%dm%s%s", color, level, devReset))
+	} else {
+		buf.AppendString(level)
+	}
+	width += 1 + len(level)
+
+	if ent.Caller.Defined {
+		caller := fmt.Sprintf("%-*s", devCallerWidth, ent.Caller.TrimmedPath())
+		buf.AppendByte(' ')
+		e.style(buf, devDim, caller)
+		width += 1 + len(caller)
+	}
+
+	// the name and the message share a column
+	column := width
+	if ent.LoggerName != "" {
+		buf.AppendByte(' ')
+		e.style(buf, devBold, ent.LoggerName+":")
+		width += 2 + len(ent.LoggerName)
+	}
+
+	buf.AppendByte(' ')
+	buf.AppendString(ent.Message)
+	width += 1 + len(ent.Message)
+
+	// the single line fields follow the message if they fit in the
+	// terminal, one per line otherwise, the multi-line ones come last
+	var inline, multiline []devField
+	prefix := ""
+	for _, f := range c.fields {
+		if f.namespace {
+			prefix += f.key + "."
+
+			continue
+		}
+
+		f.key = prefix + f.key
+		if isMultiline(f.value) {
+			multiline = append(multiline, f)
+		} else {
+			inline = append(inline, f)
+		}
+	}
+
+	values := make([]string, len(inline))
+	inlineWidth := 0
+	for i, f := range inline {
+		values[i] = devValue(f.value)
+		inlineWidth += 1 + len(f.key) + 1 + len(values[i])
+	}
+
+	if len(inline) > 0 {
+		pad := column + 1 + devMessageWidth - width
+		if pad < 0 {
+			pad = 0
+		}
+
+		if termWidth := e.width(); termWidth > 0 && width+pad+inlineWidth > termWidth {
+			for i, f := range inline {
+				buf.AppendString("\n" + devIndent)
+				e.field(buf, f.key, values[i])
+			}
+		} else {
+			buf.AppendString(strings.Repeat(" ", pad))
+			for i, f := range inline {
+				buf.AppendByte(' ')
+				e.field(buf, f.key, values[i])
+			}
+		}
+	}
+
+	for _, f := range multiline {
+		buf.AppendString("\n" + devIndent)
+		e.field(buf, f.key, prettyJSON(f.value, devIndent))
+	}
+
+	if ent.Stack != "" {
+		e.stack(buf, ent.Stack)
+	}
+
+	buf.AppendString(zapcore.DefaultLineEnding)
+
+	return buf, nil
+}
+
+func (e *devEncoder) style(buf *buffer.Buffer, style, s string) {
+	if !e.color {
+		buf.AppendString(s)
+
+		return
+	}
+
+	buf.AppendString(style)
+	buf.AppendString(s)
+	buf.AppendString(devReset)
+}
+
+func (e *devEncoder) field(buf *buffer.Buffer, key, value string) {
+	e.style(buf, devKey, key+"=")
+	buf.AppendString(value)
+}
+
+// stack writes the frames of a zap stack trace, function and file:line
+// lines, one frame per line
+func (e *devEncoder) stack(buf *buffer.Buffer, stack string) {
+	lines := strings.Split(strings.TrimRight(stack, "\n"), "\n")
+	for i := 0; i < len(lines); i += 2 {
+		function := lines[i]
+		location := ""
+		if i+1 < len(lines) {
+			location = strings.TrimSpace(lines[i+1])
+		}
+
+		style := devDim
+		if e.moduleFrame(function) {
+			style = devModule
+		}
+
+		buf.AppendString("\n" + devIndent)
+		e.style(buf, style, "at "+function+" ("+location+")")
+	}
+}
+
+// moduleFrame reports whether a function belongs to the main module, the
+// main package included
+func (e *devEncoder) moduleFrame(function string) bool {
+	if strings.HasPrefix(function, "main.") {
+		return true
+	}
+
+	return e.module != "" && (strings.HasPrefix(function, funcPrefix(e.module)) || strings.HasPrefix(function, e.module+"/"))
+}
+
+// isMultiline reports whether a value is rendered as indented JSON, the
+// objects and the arrays holding objects
+func isMultiline(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return len(v) > 0
+	case []interface{}:
+		for _, e := range v {
+			if isMultiline(e) {
+				return true
+			}
+		}
+	case json.RawMessage:
+		return len(v) > 0 && (v[0] == '{' || v[0] == '[') && len(v) > 2
+	}
+
+	return false
+}
+
+// devValue renders a single line value, strings are quoted if needed
+func devValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if v == "" || strings.ContainsAny(v, " \t\r\n\"=") || !strconv.CanBackquote(v) {
+			return strconv.Quote(v)
+		}
+
+		return v
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(TimeLayout)
+	case json.RawMessage:
+		return string(v)
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, e := range v {
+			values[i] = devValue(e)
+		}
+
+		return "[" + strings.Join(values, ", ") + "]"
+	case []byte, nil:
+		data, _ := json.Marshal(v)
+
+		return string(data)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// prettyJSON renders a value as indented JSON, the durations as text
+func prettyJSON(v interface{}, prefix string) string {
+	var b strings.Builder
+	writePrettyJSON(&b, v, prefix)
+
+	return b.String()
+}
+
+func writePrettyJSON(b *strings.Builder, v interface{}, prefix string) {
+	indent := prefix + "  "
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			b.WriteString("{}")
+
+			return
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b.WriteString("{")
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n" + indent + strconv.Quote(k) + ": ")
+			writePrettyJSON(b, v[k], indent)
+		}
+		b.WriteString("\n" + prefix + "}")
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString("[]")
+
+			return
+		}
+
+		b.WriteString("[")
+		for i, e := range v {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n" + indent)
+			writePrettyJSON(b, e, indent)
+		}
+		b.WriteString("\n" + prefix + "]")
+	case time.Duration:
+		b.WriteString(strconv.Quote(v.String()))
+	case time.Time:
+		b.WriteString(strconv.Quote(v.Format(TimeLayout)))
+	case json.RawMessage:
+		var out bytes.Buffer
+		if err := json.Indent(&out, v, prefix, "  "); err != nil {
+			b.Write(v)
+
+			return
+		}
+		b.Write(out.Bytes())
+	default:
+		data, err := json.MarshalIndent(v, prefix, "  ")
+		if err != nil {
+			b.WriteString(strconv.Quote(fmt.Sprint(v)))
+
+			return
+		}
+		b.Write(data)
+	}
+}
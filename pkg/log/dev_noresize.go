@@ -0,0 +1,8 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package log
+
+// watchResize does nothing, the platform has no resize signal: the width
+// of the terminal is the one it had when the logger was created
+func watchResize(func()) {}
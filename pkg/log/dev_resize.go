@@ -0,0 +1,21 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchResize calls update when the terminal is resized
+func watchResize(update func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGWINCH)
+	go func() {
+		for range c {
+			update()
+		}
+	}()
+}
@@ -0,0 +1,141 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var devTestEntry = zapcore.Entry{
+	Level:      zapcore.InfoLevel,
+	Time:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local),
+	LoggerName: "svc",
+	Message:    "hello",
+	Caller:     zapcore.NewEntryCaller(0, "/src/app/pkg/file.go", 12, true),
+}
+
+// newTestDevEncoder returns an uncolored encoder of a terminal width wide
+func newTestDevEncoder(width int) *devEncoder {
+	e := newDevEncoder(false, nil)
+	e.module = "example.com/app"
+	e.width = func() int { return width }
+
+	return e
+}
+
+func encodeDev(t *testing.T, e *devEncoder, ent zapcore.Entry, fields ...zapcore.Field) string {
+	t.Helper()
+
+	buf, err := e.EncodeEntry(ent, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buf.Free()
+
+	return buf.String()
+}
+
+func TestDevLayout(t *testing.T) {
+	fields := []zapcore.Field{zap.String("user", "bob"), zap.Duration("took", 1500*time.Millisecond), zap.String("note", "two words")}
+	columns := "2024-01-02 03:04:05.000 INFO   pkg/file.go:12           svc: hello"
+	// the fields start after the message column
+	pad := strings.Repeat(" ", len("2024-01-02 03:04:05.000 INFO   pkg/file.go:12           ")+devMessageWidth-len(columns))
+
+	got := encodeDev(t, newTestDevEncoder(0), devTestEntry, fields...)
+	want := columns + pad + ` user=bob took=1.5s note="two words"` + "\n"
+	if got != want {
+		t.Fatalf("encoded\n%q\nwant\n%q", got, want)
+	}
+
+	// the fields too wide for the terminal go one per line
+	got = encodeDev(t, newTestDevEncoder(80), devTestEntry, fields...)
+	want = columns + "\n    user=bob\n    took=1.5s\n    note=\"two words\"\n"
+	if got != want {
+		t.Fatalf("encoded\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestDevPrettyJSON(t *testing.T) {
+	obj := zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("name", "bob")
+		enc.AddDuration("ttl", time.Minute)
+
+		return enc.AddArray("roles", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			arr.AppendString("admin")
+
+			return nil
+		}))
+	})
+
+	e := newTestDevEncoder(0).Clone().(*devEncoder)
+	e.OpenNamespace("req")
+	got := encodeDev(t, e, devTestEntry, zap.Object("user", obj), zap.Int("n", 1))
+
+	// the objects come last, indented, the keys of a namespace prefixed
+	lines := strings.SplitN(got, "\n", 2)
+	if !strings.HasSuffix(lines[0], " req.n=1") {
+		t.Fatalf("first line %q, want the inline field", lines[0])
+	}
+	want := `    req.user={
+      "name": "bob",
+      "roles": [
+        "admin"
+      ],
+      "ttl": "1m0s"
+    }
+`
+	if lines[1] != want {
+		t.Fatalf("object encoded\n%s\nwant\n%s", lines[1], want)
+	}
+}
+
+func TestDevStack(t *testing.T) {
+	e := newTestDevEncoder(0)
+	e.color = true
+	ent := devTestEntry
+	ent.Stack = "example.com/app/pkg.Handle\n\t/src/app/pkg/file.go:12\nmain.main\n\t/src/app/main.go:5\nruntime.main\n\t/usr/local/go/src/runtime/proc.go:250"
+
+	got := encodeDev(t, e, ent)
+	for _, want := range []string{
+		"\n" + devIndent + devModule + "at example.com/app/pkg.Handle (/src/app/pkg/file.go:12)" + devReset,
+		"\n" + devIndent + devModule + "at main.main (/src/app/main.go:5)" + devReset,
+		"\n" + devIndent + devDim + "at runtime.main (/usr/local/go/src/runtime/proc.go:250)" + devReset,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("encoded %q, want the frame %q", got, want)
+		}
+	}
+}
+
+func TestDevColorOutputs(t *testing.T) {
+	opts := NewOptions()
+	opts.Format = consoleFormat
+	opts.EnableColor = true
+
+	// a file is never a terminal, whatever stdout is
+	opts.OutputPaths = []string{"stdout", filepath.Join(t.TempDir(), "test.log")}
+	if colorEnabled(opts) {
+		t.Fatal("color enabled with a file output")
+	}
+
+	opts.OutputPaths = []string{"stderr"}
+	if got, want := colorEnabled(opts), isTerminal(os.Stderr); got != want {
+		t.Fatalf("color enabled %v for stderr, want %v", got, want)
+	}
+}
+
+func TestDevWidthWithoutTerminal(t *testing.T) {
+	t.Setenv("COLUMNS", "42")
+	width := widthOf(nil)
+
+	// the width is read once
+	os.Setenv("COLUMNS", "80")
+	if got := width(); got != 42 {
+		t.Fatalf("width %d, want COLUMNS when the encoder was created", got)
+	}
+}
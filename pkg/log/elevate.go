@@ -0,0 +1,263 @@
+package log
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// HeaderRequestID carries the request ID of a request
+	HeaderRequestID = "X-Request-ID"
+	// HeaderDebug carries a token signed by DebugElevator.Token to log
+	// the request at debug level
+	HeaderDebug = "X-Debug-Log"
+)
+
+// WithDebug marks ctx so that the loggers of C and FromContext emit debug
+// entries, whatever the level of the logger is.
+func WithDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugContextKey, true)
+}
+
+// DebugElevated reports whether ctx was marked by WithDebug
+func DebugElevated(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	elevated, _ := ctx.Value(debugContextKey).(bool)
+
+	return elevated
+}
+
+// elevate returns a child logger enabled at debug level
+func (l *zapLogger) elevate() *zapLogger {
+	return l.derive(l.zapLogger.WithOptions(zap.WrapCore(elevateCore)))
+}
+
+// elevator is implemented by the cores which can be enabled at debug level
+type elevator interface {
+	elevate() zapcore.Core
+}
+
+func elevateCore(c zapcore.Core) zapcore.Core {
+	if e, ok := c.(elevator); ok {
+		return e.elevate()
+	}
+
+	return c
+}
+
+// levelCore filters the entries of the sinks core by the logger level.
+// The sinks core itself is enabled at the lowest level, so that levelCore
+// can be elevated for a single request.
+type levelCore struct {
+	zapcore.Core
+	level levelEnabler
+}
+
+func newLevelCore(core zapcore.Core, level levelEnabler) *levelCore {
+	return &levelCore{
+		Core:  core,
+		level: level,
+	}
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{
+		Core:  c.Core.With(fields),
+		level: c.level,
+	}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.level.Enabled(ent.Level) {
+		return c.Core.Check(ent, ce)
+	}
+
+	return ce
+}
+
+func (c *levelCore) elevate() zapcore.Core {
+	return &levelCore{
+		Core:  c.Core,
+		level: levelEnabler(elevatedLevel(Level(c.level))),
+	}
+}
+
+// elevatedLevel returns the level of an elevated logger, the lower of its
+// level and DebugLevel
+func elevatedLevel(level Level) Level {
+	if levelRank(level) < levelRank(DebugLevel) {
+		return level
+	}
+
+	return DebugLevel
+}
+
+// DebugElevator decides which requests are logged at debug level, by a
+// request ID allowlist or by a token signed with a shared secret.
+type DebugElevator struct {
+	secret []byte
+
+	mu         sync.RWMutex
+	requestIDs map[string]struct{}
+}
+
+// NewDebugElevator creates a DebugElevator, tokens are not accepted if
+// secret is empty.
+func NewDebugElevator(secret []byte, requestIDs ...string) *DebugElevator {
+	e := &DebugElevator{
+		secret:     secret,
+		requestIDs: make(map[string]struct{}, len(requestIDs)),
+	}
+	e.Allow(requestIDs...)
+
+	return e
+}
+
+// Allow adds request IDs to the allowlist
+func (e *DebugElevator) Allow(requestIDs ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, id := range requestIDs {
+		e.requestIDs[id] = struct{}{}
+	}
+}
+
+// Disallow removes request IDs from the allowlist
+func (e *DebugElevator) Disallow(requestIDs ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, id := range requestIDs {
+		delete(e.requestIDs, id)
+	}
+}
+
+// Token returns a token for HeaderDebug which is valid for ttl
+func (e *DebugElevator) Token(ttl time.Duration) string {
+	expiry := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+
+	return expiry + "." + e.sign(expiry)
+}
+
+func (e *DebugElevator) sign(expiry string) string {
+	mac := hmac.New(sha256.New, e.secret)
+	mac.Write([]byte(expiry))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (e *DebugElevator) validToken(token string) bool {
+	if len(e.secret) == 0 || token == "" {
+		return false
+	}
+
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return false
+	}
+
+	expiry, err := strconv.ParseInt(token[:i], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return false
+	}
+
+	return hmac.Equal([]byte(token[i+1:]), []byte(e.sign(token[:i])))
+}
+
+func (e *DebugElevator) allowed(requestID string) bool {
+	if requestID == "" {
+		return false
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	_, ok := e.requestIDs[requestID]
+
+	return ok
+}
+
+// Elevate marks ctx by WithDebug if the request ID is allowed or the token
+// is valid. The request ID is taken from ctx when empty.
+func (e *DebugElevator) Elevate(ctx context.Context, requestID, token string) context.Context {
+	if requestID == "" {
+		requestID, _ = ctx.Value(keyRequestID).(string)
+	}
+
+	if e.allowed(requestID) || e.validToken(token) {
+		return WithDebug(ctx)
+	}
+
+	return ctx
+}
+
+// HTTPMiddleware elevates the requests by their HeaderRequestID and
+// HeaderDebug headers
+func (e *DebugElevator) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := e.Elevate(r.Context(), r.Header.Get(HeaderRequestID), r.Header.Get(HeaderDebug))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// UnaryServerInterceptor elevates the gRPC calls by their HeaderRequestID
+// and HeaderDebug metadata
+func (e *DebugElevator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		return handler(e.elevateIncoming(ctx), req)
+	}
+}
+
+// StreamServerInterceptor elevates the gRPC streams by their HeaderRequestID
+// and HeaderDebug metadata
+func (e *DebugElevator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: e.elevateIncoming(ss.Context())})
+	}
+}
+
+func (e *DebugElevator) elevateIncoming(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	return e.Elevate(ctx, firstValue(md, HeaderRequestID), firstValue(md, HeaderDebug))
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// serverStream overrides the context of a grpc.ServerStream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,91 @@
+package log
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestElevate(t *testing.T) {
+	tests := []struct {
+		level string
+		// want are the messages logged by an elevated logger
+		want []string
+	}{
+		{level: "info", want: []string{"debug", "info"}},
+		{level: "trace", want: []string{"trace", "debug", "info"}},
+		{level: "error", want: []string{"debug", "info"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			opts := newTestOptions(t)
+			opts.Level = tt.level
+			l, entries := newTestLogger(t, opts)
+
+			elevated := l.C(WithDebug(context.Background()))
+			elevated.Trace("trace")
+			elevated.Debug("debug")
+			elevated.Info("info")
+
+			got := entries()
+			if len(got) != len(tt.want) {
+				t.Fatalf("%d entries %v, want %v", len(got), got, tt.want)
+			}
+			for i, msg := range tt.want {
+				if got[i]["message"] != msg {
+					t.Fatalf("entry %d %v, want %s", i, got[i], msg)
+				}
+			}
+
+			// the logger itself is not elevated
+			l.Debug("not elevated")
+			want := len(tt.want)
+			if l.Enabled(DebugLevel) {
+				want++
+			}
+			if n := len(entries()); n != want {
+				t.Fatalf("%d entries after a debug entry of the logger, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestElevateFromContext(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.Level = "info"
+	l, entries := newTestLogger(t, opts)
+
+	ctx := WithDebug(l.WithContext(context.Background()))
+	FromContext(ctx).Debug("debug")
+
+	if got := entries(); len(got) != 1 || got[0]["message"] != "debug" {
+		t.Fatalf("entries %v, want the debug entry", got)
+	}
+}
+
+func TestElevateTenant(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.Level = "info"
+	opts.TenantField = "tenant"
+	opts.TenantOutputPath = filepath.Join(t.TempDir(), TenantPlaceholder+".log")
+	opts.TenantLevels = map[string]string{"verbose": "trace"}
+	l, _ := newTestLogger(t, opts)
+
+	elevated := l.C(WithDebug(context.Background()))
+	for _, tenant := range []string{"verbose", "quiet"} {
+		tl := elevated.WithValues("tenant", tenant)
+		tl.Trace("trace")
+		tl.Debug("debug")
+	}
+	l.Flush()
+
+	// elevating keeps the trace level of the tenant
+	if got := readEntries(t, filepath.Join(filepath.Dir(opts.TenantOutputPath), "verbose.log")); len(got) != 2 {
+		t.Fatalf("%d entries of the trace tenant, want 2", len(got))
+	}
+	if got := readEntries(t, filepath.Join(filepath.Dir(opts.TenantOutputPath), "quiet.log")); len(got) != 1 ||
+		got[0]["message"] != "debug" {
+		t.Fatalf("entries of the info tenant %v, want the debug entry", got)
+	}
+}
@@ -0,0 +1,529 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EncryptedScheme is the URL scheme of the encrypted file sinks, such as
+// encrypted:///var/log/svc.log?key-file=/etc/svc/log.keys. The keys are
+// read from the key-file or from the key-env variable, DefaultKeyEnv by
+// default. The key-file is read again when it changes, so that the keys
+// can be rotated without a restart.
+const EncryptedScheme = "encrypted"
+
+// DefaultKeyEnv is the variable holding the keys of the encrypted sinks
+// without key-file nor key-env
+const DefaultKeyEnv = "LOG_ENCRYPTION_KEYS"
+
+// The encrypted files are a sequence of segments, each one sealing a
+// write with AES-GCM: the magic, the version, the key ID length and the
+// key ID, the nonce and the ciphertext length as big endian uint32, which
+// are authenticated, then the ciphertext. Each segment is decrypted on its
+// own, so the files can be appended to, rotated and read from any offset.
+const (
+	segmentMagic   = "ELOG"
+	segmentVersion = 1
+	// maxSegmentSize caps the plaintext of a segment, larger writes are
+	// split
+	maxSegmentSize = 1 << 20
+	// maxCiphertextSize rejects the corrupt lengths
+	maxCiphertextSize = maxSegmentSize + 64
+	// segmentNonceSize and segmentTagSize are the sizes of the nonce and
+	// of the tag of AES-GCM, the segments of unknown keys are skipped
+	// with them
+	segmentNonceSize = 12
+	segmentTagSize   = 16
+)
+
+// keyFileCheckInterval is the interval between the checks of the key files
+// of the encrypted sinks
+var keyFileCheckInterval = 10 * time.Second
+
+var (
+	// ErrUnknownKey is returned when decrypting a segment whose key isn't
+	// in the keyring
+	ErrUnknownKey = errors.New("unknown log encryption key")
+	// ErrDecrypt is returned for segments failing authentication
+	ErrDecrypt = errors.New("log segment authentication failed")
+
+	validKeyID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,255}$`)
+)
+
+func init() {
+	// the scheme is already registered by another copy of this package,
+	// vendored under another path, whose sinks are used then
+	_ = zap.RegisterSink(EncryptedScheme, newEncryptedSink)
+}
+
+// Keyring holds the keys of the encrypted logs by key ID. The last key is
+// the one encrypting, the others decrypt the segments written before a
+// rotation.
+type Keyring struct {
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+// ParseKeyring parses keys, ID=KEY entries separated by new lines, commas
+// or semicolons, KEY is a base64 AES key of 16, 24 or 32 bytes. The lines
+// starting with # are ignored.
+func ParseKeyring(keys string) (*Keyring, error) {
+	kr := &Keyring{keys: map[string]cipher.AEAD{}}
+
+	entries := strings.FieldsFunc(keys, func(r rune) bool {
+		return r == '\n' || r == ',' || r == ';'
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, "=")
+		id = strings.TrimSpace(id)
+		if !ok || !validKeyID.MatchString(id) {
+			return nil, fmt.Errorf("invalid log encryption key entry, expect ID=KEY: %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("log encryption key %q: %w", id, err)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("log encryption key %q: %w", id, err)
+		}
+
+		if kr.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+		kr.active = id
+	}
+
+	if kr.active == "" {
+		return nil, errors.New("no log encryption key")
+	}
+
+	return kr, nil
+}
+
+// LoadKeyring reads the keys of a key file, in the format of ParseKeyring
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseKeyring(string(data))
+}
+
+// KeyringFromEnv reads the keys of an environment variable, in the format
+// of ParseKeyring
+func KeyringFromEnv(name string) (*Keyring, error) {
+	keys, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("log encryption keys variable %s is not set", name)
+	}
+
+	return ParseKeyring(keys)
+}
+
+// ActiveKeyID returns the ID of the key encrypting
+func (kr *Keyring) ActiveKeyID() string {
+	return kr.active
+}
+
+// seal encrypts p into a segment with the active key and the next nonce
+// of nonces
+func (kr *Keyring) seal(dst, p []byte, nonces *nonceSequence) ([]byte, error) {
+	aead := kr.keys[kr.active]
+
+	header := make([]byte, 0, len(segmentMagic)+2+len(kr.active)+segmentNonceSize+4)
+	header = append(header, segmentMagic...)
+	header = append(header, segmentVersion, byte(len(kr.active)))
+	header = append(header, kr.active...)
+
+	nonce, err := nonces.next()
+	if err != nil {
+		return nil, err
+	}
+	header = append(header, nonce...)
+	header = append(header, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(header[len(header)-4:], uint32(len(p)+aead.Overhead()))
+
+	dst = append(dst, header...)
+
+	return aead.Seal(dst, nonce, p, header), nil
+}
+
+// nonceSequence generates the nonces of a sink, a random prefix followed
+// by a counter. Random nonces of 96 bits collide after about 2^32 segments
+// of a key, the counter doesn't repeat within a sink and the prefix, drawn
+// again when the counter wraps, separates the sinks.
+type nonceSequence struct {
+	prefix  [segmentNonceSize - 4]byte
+	counter uint32
+}
+
+func (s *nonceSequence) next() ([]byte, error) {
+	if s.counter == 0 {
+		if _, err := rand.Read(s.prefix[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	nonce := make([]byte, segmentNonceSize)
+	copy(nonce, s.prefix[:])
+	binary.BigEndian.PutUint32(nonce[len(s.prefix):], s.counter)
+	s.counter++
+
+	return nonce, nil
+}
+
+// encryptedSink writes the entries to a file, each write sealed into a
+// segment
+type encryptedSink struct {
+	mu      sync.Mutex
+	file    *os.File
+	keyring *Keyring
+	nonces  nonceSequence
+	buf     []byte
+
+	// keyFile is read again when it changes, checked every
+	// keyFileCheckInterval
+	keyFile    string
+	keyModTime time.Time
+	checked    time.Time
+}
+
+// newEncryptedSink opens the sink of an encrypted URL
+func newEncryptedSink(u *url.URL) (zap.Sink, error) {
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+	if path == "" {
+		return nil, fmt.Errorf("encrypted sink without path: %s", u)
+	}
+
+	query := u.Query()
+	var (
+		kr      *Keyring
+		err     error
+		modTime time.Time
+	)
+	keyFile := query.Get("key-file")
+	switch {
+	case keyFile != "":
+		var info os.FileInfo
+		if info, err = os.Stat(keyFile); err == nil {
+			modTime = info.ModTime()
+			kr, err = LoadKeyring(keyFile)
+		}
+	case query.Get("key-env") != "":
+		kr, err = KeyringFromEnv(query.Get("key-env"))
+	default:
+		kr, err = KeyringFromEnv(DefaultKeyEnv)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedSink{file: file, keyring: kr, keyFile: keyFile, keyModTime: modTime, checked: time.Now()}, nil
+}
+
+// reloadKeyring reads the key file again if it changed since it was read.
+// The keyring is kept if the file can't be read, until it is fixed.
+func (s *encryptedSink) reloadKeyring() {
+	if s.keyFile == "" || time.Since(s.checked) < keyFileCheckInterval {
+		return
+	}
+	s.checked = time.Now()
+
+	info, err := os.Stat(s.keyFile)
+	if err != nil || info.ModTime().Equal(s.keyModTime) {
+		return
+	}
+
+	if kr, err := LoadKeyring(s.keyFile); err == nil {
+		s.keyring = kr
+		s.keyModTime = info.ModTime()
+	}
+}
+
+// Write writes p in a single write, so that the segments of the processes
+// appending to the file don't interleave
+func (s *encryptedSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reloadKeyring()
+	s.buf = s.buf[:0]
+	for rest := p; len(rest) > 0; {
+		n := len(rest)
+		if n > maxSegmentSize {
+			n = maxSegmentSize
+		}
+
+		var err error
+		if s.buf, err = s.keyring.seal(s.buf, rest[:n], &s.nonces); err != nil {
+			return 0, err
+		}
+		rest = rest[n:]
+	}
+
+	if _, err := s.file.Write(s.buf); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (s *encryptedSink) Sync() error {
+	return s.file.Sync()
+}
+
+func (s *encryptedSink) Close() error {
+	return s.file.Close()
+}
+
+type decryptOptions struct {
+	skipHandler ErrorHandler
+}
+
+// DecryptOption optional parameters for NewDecryptReader
+type DecryptOption func(*decryptOptions)
+
+// WithSkipHandler reports the skipped segments to h: those whose key isn't
+// in the keyring, the errors wrap ErrUnknownKey, and the bytes skipped
+// after a segment failing authentication or torn, they wrap ErrDecrypt
+func WithSkipHandler(h ErrorHandler) DecryptOption {
+	return func(o *decryptOptions) {
+		o.skipHandler = h
+	}
+}
+
+// decryptReader reads the plaintext of the segments
+type decryptReader struct {
+	r       *bufio.Reader
+	keyring *Keyring
+	opts    decryptOptions
+	plain   []byte
+	// resync is set while looking for a segment after invalid bytes, the
+	// invalid key IDs are then skipped
+	resync bool
+	// failed is the failure of the segment the bytes are skipped since,
+	// skipped counts them
+	failed  error
+	skipped int
+}
+
+// NewDecryptReader returns the plaintext of the encrypted logs read from
+// r. The bytes before the first segment, as when reading from an offset,
+// are skipped, a truncated last segment returns io.ErrUnexpectedEOF. The
+// segments of the keys missing from the keyring, failing authentication
+// or torn, as by a crash in the middle of a write, are skipped and the
+// segments after them are still read.
+func NewDecryptReader(r io.Reader, kr *Keyring, opts ...DecryptOption) io.Reader {
+	d := &decryptReader{r: bufio.NewReader(r), keyring: kr}
+	for _, opt := range opts {
+		opt(&d.opts)
+	}
+
+	return d
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		plain, err := d.segment()
+		if err != nil {
+			return 0, err
+		}
+		d.plain = plain
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+
+	return n, nil
+}
+
+// segment reads and decrypts the next segment
+func (d *decryptReader) segment() ([]byte, error) {
+	for {
+		magic, err := d.r.Peek(len(segmentMagic))
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(magic) == 0 && d.failed == nil {
+				return nil, io.EOF
+			}
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+
+		if !bytes.Equal(magic, []byte(segmentMagic)) {
+			// look for the next segment
+			d.resync = true
+			d.skip(1)
+
+			continue
+		}
+
+		plain, err := d.open()
+		if err == nil {
+			d.endResync()
+
+			return plain, nil
+		}
+
+		if errors.Is(err, ErrUnknownKey) {
+			// open skipped the segment
+			d.endResync()
+			d.report(err)
+
+			continue
+		}
+
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// the length of a torn segment may cover the next ones
+			err = fmt.Errorf("%w: truncated segment", ErrDecrypt)
+			d.skip(1)
+		} else if errors.Is(err, ErrDecrypt) {
+			// open moved past the magic
+			d.skipped++
+		} else {
+			return nil, err
+		}
+
+		d.resync = true
+		if d.failed == nil {
+			d.failed = err
+		}
+	}
+}
+
+func (d *decryptReader) skip(n int) {
+	n, _ = d.r.Discard(n)
+	d.skipped += n
+}
+
+// endResync reports the bytes skipped since a segment failed, the bytes
+// before the first segment are skipped silently
+func (d *decryptReader) endResync() {
+	if d.failed != nil {
+		d.report(fmt.Errorf("%w, %d bytes skipped", d.failed, d.skipped))
+	}
+	d.resync, d.failed, d.skipped = false, nil, 0
+}
+
+func (d *decryptReader) report(err error) {
+	if d.opts.skipHandler != nil {
+		d.opts.skipHandler.OnError(err)
+	}
+}
+
+// open decrypts the segment at the reader position. In resync mode, the
+// reader only moves past the magic if the segment is invalid.
+func (d *decryptReader) open() ([]byte, error) {
+	fixed, err := d.r.Peek(len(segmentMagic) + 2)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	idLen := int(fixed[len(segmentMagic)+1])
+	if fixed[len(segmentMagic)] != segmentVersion || idLen == 0 {
+		_, _ = d.r.Discard(1)
+
+		return nil, fmt.Errorf("%w: invalid segment header", ErrDecrypt)
+	}
+
+	head, err := d.r.Peek(len(segmentMagic) + 2 + idLen)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	id := string(head[len(segmentMagic)+2:])
+	if d.resync && !validKeyID.MatchString(id) {
+		_, _ = d.r.Discard(1)
+
+		return nil, fmt.Errorf("%w: invalid key id", ErrDecrypt)
+	}
+
+	headerSize := len(head) + segmentNonceSize + 4
+	header, err := d.r.Peek(headerSize)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	size := int(binary.BigEndian.Uint32(header[headerSize-4:]))
+	if size < segmentTagSize || size > maxCiphertextSize {
+		_, _ = d.r.Discard(1)
+
+		return nil, fmt.Errorf("%w: invalid segment length %d", ErrDecrypt, size)
+	}
+
+	aead, ok := d.keyring.keys[id]
+	if !ok {
+		if _, err := d.r.Discard(headerSize + size); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+
+	segment, err := d.r.Peek(headerSize + size)
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// the segment is larger than the buffer of the reader
+			d.r = bufio.NewReaderSize(d.r, headerSize+size)
+			segment, err = d.r.Peek(headerSize + size)
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+
+	nonce := segment[len(head) : len(head)+segmentNonceSize]
+	plain, err := aead.Open(nil, nonce, segment[headerSize:], segment[:headerSize])
+	if err != nil {
+		_, _ = d.r.Discard(1)
+
+		return nil, fmt.Errorf("%w: key %q", ErrDecrypt, id)
+	}
+
+	_, _ = d.r.Discard(headerSize + size)
+
+	return plain, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
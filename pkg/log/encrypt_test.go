@@ -0,0 +1,257 @@
+package log
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// newTestKey returns an entry of a keyring, ID=KEY with a random key
+func newTestKey(t *testing.T, id string) string {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	return id + "=" + base64.StdEncoding.EncodeToString(key)
+}
+
+func parseTestKeyring(t *testing.T, keys ...string) *Keyring {
+	t.Helper()
+
+	kr, err := ParseKeyring(strings.Join(keys, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kr
+}
+
+// openEncrypted opens the encrypted sink of url, closed with the test
+func openEncrypted(t *testing.T, url string) func(string) {
+	t.Helper()
+
+	ws, closeSink, err := zap.Open(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(closeSink)
+
+	return func(s string) {
+		t.Helper()
+
+		if _, err := ws.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func decryptAll(t *testing.T, data []byte, kr *Keyring, opts ...DecryptOption) (string, error) {
+	t.Helper()
+
+	plain, err := io.ReadAll(NewDecryptReader(bytes.NewReader(data), kr, opts...))
+
+	return string(plain), err
+}
+
+type skippedSegments []error
+
+func (s *skippedSegments) OnError(err error) {
+	*s = append(*s, err)
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	key := newTestKey(t, "k1")
+	t.Setenv("TEST_LOG_KEYS", key)
+	path := filepath.Join(t.TempDir(), "enc.log")
+	write := openEncrypted(t, EncryptedScheme+"://"+path+"?key-env=TEST_LOG_KEYS")
+
+	large := strings.Repeat("x", maxSegmentSize+10)
+	write("first\n")
+	write(large)
+	write("last\n")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("first")) {
+		t.Fatal("plaintext in the encrypted file")
+	}
+
+	got, err := decryptAll(t, data, parseTestKeyring(t, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "first\n"+large+"last\n" {
+		t.Fatalf("decrypted %d bytes, want the writes", len(got))
+	}
+}
+
+// sealSegments returns the segments of the plaintexts sealed with kr
+func sealSegments(t *testing.T, kr *Keyring, plains ...string) []byte {
+	t.Helper()
+
+	var nonces nonceSequence
+	var data []byte
+	for _, p := range plains {
+		var err error
+		if data, err = kr.seal(data, []byte(p), &nonces); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return data
+}
+
+func TestDecryptTampered(t *testing.T) {
+	kr := parseTestKeyring(t, newTestKey(t, "k1"))
+	data := sealSegments(t, kr, "first\n", "second\n")
+
+	// a byte of the ciphertext of the first segment
+	first := len(sealSegments(t, kr, "first\n"))
+	data[first-segmentTagSize-1] ^= 1
+
+	// the tampered segment is skipped and reported
+	var skipped skippedSegments
+	got, err := decryptAll(t, data, kr, WithSkipHandler(&skipped))
+	if err != nil || got != "second\n" {
+		t.Fatalf("decrypted %q, %v, want the segment after the tampered one", got, err)
+	}
+	if len(skipped) != 1 || !errors.Is(skipped[0], ErrDecrypt) {
+		t.Fatalf("skipped %v, want the tampered segment", skipped)
+	}
+}
+
+func TestDecryptTornSegment(t *testing.T) {
+	kr := parseTestKeyring(t, newTestKey(t, "k1"))
+	for _, next := range []string{"3\n", strings.Repeat("third ", 100) + "\n"} {
+		// a crash in the middle of the second write, then appends after a
+		// restart
+		torn := sealSegments(t, kr, "second "+strings.Repeat("x", 200)+"\n")
+		data := sealSegments(t, kr, "first\n")
+		data = append(data, torn[:len(torn)/2]...)
+		data = append(data, sealSegments(t, kr, next, "last\n")...)
+
+		var skipped skippedSegments
+		got, err := decryptAll(t, data, kr, WithSkipHandler(&skipped))
+		if err != nil || got != "first\n"+next+"last\n" {
+			t.Fatalf("decrypted %q, %v, want the segments around the torn one", got, err)
+		}
+		if len(skipped) != 1 || !errors.Is(skipped[0], ErrDecrypt) || !strings.Contains(skipped[0].Error(), "bytes skipped") {
+			t.Fatalf("skipped %v, want the torn segment", skipped)
+		}
+	}
+}
+
+func TestDecryptResync(t *testing.T) {
+	kr := parseTestKeyring(t, newTestKey(t, "k1"))
+	first := sealSegments(t, kr, "first\n")
+	data := append(append([]byte(nil), first...), sealSegments(t, kr, "second\n", "third\n")...)
+
+	// read from an offset in the first segment
+	got, err := decryptAll(t, data[5:], kr)
+	if err != nil || got != "second\nthird\n" {
+		t.Fatalf("decrypted %q, %v, want the segments after the offset", got, err)
+	}
+
+	// garbage, then a tampered segment found while resyncing
+	tampered := append([]byte("garbage"), data...)
+	tampered[len("garbage")+len(first)-1] ^= 1
+	got, err = decryptAll(t, tampered, kr)
+	if err != nil || got != "second\nthird\n" {
+		t.Fatalf("decrypted %q, %v, want the valid segments", got, err)
+	}
+
+	// a truncated last segment
+	if _, err := decryptAll(t, data[:len(data)-1], kr); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("read %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestDecryptUnknownKey(t *testing.T) {
+	old, current := newTestKey(t, "old"), newTestKey(t, "current")
+	data := sealSegments(t, parseTestKeyring(t, old), "first\n")
+	data = append(data, sealSegments(t, parseTestKeyring(t, current), "second\n")...)
+	data = append(data, sealSegments(t, parseTestKeyring(t, old), "third\n")...)
+
+	// the segments of the old key are skipped and reported
+	var skipped skippedSegments
+	got, err := decryptAll(t, data, parseTestKeyring(t, current), WithSkipHandler(&skipped))
+	if err != nil || got != "second\n" {
+		t.Fatalf("decrypted %q, %v, want the segment of the known key", got, err)
+	}
+	if len(skipped) != 2 || !errors.Is(skipped[0], ErrUnknownKey) || !strings.Contains(skipped[0].Error(), `"old"`) {
+		t.Fatalf("skipped %v, want the two segments of the old key", skipped)
+	}
+}
+
+func TestEncryptedKeyFileReload(t *testing.T) {
+	check := keyFileCheckInterval
+	keyFileCheckInterval = 0
+	t.Cleanup(func() { keyFileCheckInterval = check })
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "log.keys")
+	k1, k2 := newTestKey(t, "k1"), newTestKey(t, "k2")
+	if err := os.WriteFile(keyFile, []byte(k1+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "enc.log")
+	write := openEncrypted(t, EncryptedScheme+"://"+path+"?key-file="+keyFile)
+	write("before\n")
+
+	// a new active key is added to the key file
+	if err := os.WriteFile(keyFile, []byte(k1+"\n"+k2+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(keyFile, future, future); err != nil {
+		t.Fatal(err)
+	}
+	write("after\n")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decryptAll(t, data, parseTestKeyring(t, k2))
+	if err != nil || got != "after\n" {
+		t.Fatalf("decrypted %q, %v, want the write after the rotation", got, err)
+	}
+}
+
+func TestNonceSequence(t *testing.T) {
+	var s nonceSequence
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		nonce, err := s.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[string(nonce)] {
+			t.Fatalf("nonce %x repeated", nonce)
+		}
+		seen[string(nonce)] = true
+	}
+
+	// the prefix is drawn again when the counter wraps
+	s.counter = math.MaxUint32
+	last, _ := s.next()
+	wrapped, _ := s.next()
+	if bytes.Equal(last[:len(s.prefix)], wrapped[:len(s.prefix)]) {
+		t.Fatal("prefix kept after the counter wrapped")
+	}
+}
@@ -0,0 +1,103 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Shutdowner runs the graceful shutdown of the application.
+// shutdown.TriggerManager implements it.
+type Shutdowner interface {
+	// Trigger starts the shutdown and returns when it has finished or ctx is done.
+	Trigger(ctx context.Context) error
+}
+
+// fatalHook replaces the os.Exit(1) zap calls after writing a fatal entry.
+// It triggers the graceful shutdown, flushes the sinks and then exits with
+// the configured code.
+type fatalHook struct {
+	shutdowner Shutdowner
+	timeout    time.Duration
+	code       int
+	// sync flushes the sinks of the logger, set once the logger is built
+	sync func() error
+	// exit ends the process, os.Exit but in tests
+	exit func(int)
+	// exiting is set by the first fatal entry
+	exiting int32
+	// reentrantWait bounds the wait of the next fatal entries for the first
+	// one when timeout doesn't
+	reentrantWait time.Duration
+}
+
+// defaultReentrantWait is the wait of the fatal entries logged while
+// another one shuts down the process without a timeout
+const defaultReentrantWait = 5 * time.Second
+
+func newFatalHook(opts *Options) *fatalHook {
+	code := opts.FatalExitCode
+	if code == 0 {
+		code = 1
+	}
+
+	return &fatalHook{
+		shutdowner:    opts.Shutdowner,
+		timeout:       opts.FatalShutdownTimeout,
+		code:          code,
+		exit:          os.Exit,
+		reentrantWait: defaultReentrantWait,
+	}
+}
+
+func (h *fatalHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	if !atomic.CompareAndSwapInt32(&h.exiting, 0, 1) {
+		// another fatal entry is already shutting down the process. The
+		// caller must not return, it waits for the first entry to exit and
+		// exits in its place past the deadline: the first entry may wait
+		// for the caller, e.g. a shutdown callback logging a fatal entry.
+		wait := h.timeout
+		if wait <= 0 {
+			wait = h.reentrantWait
+		}
+		time.Sleep(wait)
+		h.exit(h.code)
+
+		return
+	}
+
+	// the shutdown and the flush share the deadline, the sinks such as the
+	// OTLP exporter could otherwise keep the process from exiting
+	ctx := context.Background()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	if h.shutdowner != nil {
+		if err := h.shutdowner.Trigger(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "graceful shutdown on fatal log: %v\n", err)
+		}
+	}
+
+	if h.sync != nil {
+		synced := make(chan struct{})
+		go func() {
+			_ = h.sync()
+			close(synced)
+		}()
+
+		select {
+		case <-synced:
+		case <-ctx.Done():
+			fmt.Fprintf(os.Stderr, "flush on fatal log: %v\n", ctx.Err())
+		}
+	}
+
+	h.exit(h.code)
+}
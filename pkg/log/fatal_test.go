@@ -0,0 +1,192 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type shutdownerFunc func(ctx context.Context) error
+
+func (f shutdownerFunc) Trigger(ctx context.Context) error {
+	return f(ctx)
+}
+
+// newTestFatalHook returns a hook recording its exit codes on exits
+func newTestFatalHook(opts *Options, exits chan<- int) *fatalHook {
+	h := newFatalHook(opts)
+	h.exit = func(code int) { exits <- code }
+
+	return h
+}
+
+func TestFatalHookShutdown(t *testing.T) {
+	var triggered, synced int32
+	opts := NewOptions()
+	opts.FatalExitCode = 3
+	opts.Shutdowner = shutdownerFunc(func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("shutdown without deadline")
+		}
+		if atomic.LoadInt32(&synced) != 0 {
+			t.Error("sinks flushed before the shutdown")
+		}
+		atomic.AddInt32(&triggered, 1)
+
+		return nil
+	})
+
+	exits := make(chan int, 1)
+	h := newTestFatalHook(opts, exits)
+	h.sync = func() error {
+		atomic.AddInt32(&synced, 1)
+
+		return nil
+	}
+	h.OnWrite(nil, nil)
+
+	if code := <-exits; code != 3 {
+		t.Fatalf("exit code %d, want 3", code)
+	}
+	if triggered != 1 || synced != 1 {
+		t.Fatalf("%d shutdowns and %d flushes, want 1", triggered, synced)
+	}
+}
+
+func TestFatalHookTimeout(t *testing.T) {
+	opts := NewOptions()
+	opts.FatalShutdownTimeout = 50 * time.Millisecond
+	opts.Shutdowner = shutdownerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+
+		return errors.New("shutdown timed out")
+	})
+
+	exits := make(chan int, 1)
+	h := newTestFatalHook(opts, exits)
+	block := make(chan struct{})
+	defer close(block)
+	h.sync = func() error {
+		// a sink which never returns
+		<-block
+
+		return nil
+	}
+
+	start := time.Now()
+	h.OnWrite(nil, nil)
+
+	if code := <-exits; code != 1 {
+		t.Fatalf("exit code %d, want 1", code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("exited after %v, want the shutdown timeout", elapsed)
+	}
+}
+
+func TestFatalHookConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	opts := NewOptions()
+	opts.Shutdowner = shutdownerFunc(func(ctx context.Context) error {
+		<-release
+
+		return nil
+	})
+
+	exits := make(chan int, 2)
+	h := newTestFatalHook(opts, exits)
+
+	first := make(chan struct{})
+	go func() {
+		h.OnWrite(nil, nil)
+		close(first)
+	}()
+	for atomic.LoadInt32(&h.exiting) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the second fatal entry waits for the first one to exit
+	second := make(chan struct{})
+	go func() {
+		h.OnWrite(nil, nil)
+		close(second)
+	}()
+
+	close(release)
+	<-first
+
+	select {
+	case <-second:
+		t.Fatal("second fatal entry returned")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if len(exits) != 1 {
+		t.Fatalf("%d exits, want 1", len(exits))
+	}
+}
+
+func TestFatalHookReentrant(t *testing.T) {
+	exits := make(chan int, 2)
+
+	opts := NewOptions()
+	opts.FatalExitCode = 3
+	opts.FatalShutdownTimeout = 0
+
+	var h *fatalHook
+	opts.Shutdowner = shutdownerFunc(func(ctx context.Context) error {
+		// a shutdown callback logging a fatal entry, the first one waits
+		// for it without a deadline
+		h.OnWrite(nil, nil)
+
+		return nil
+	})
+	h = newTestFatalHook(opts, exits)
+	h.reentrantWait = 10 * time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		h.OnWrite(nil, nil)
+		close(done)
+	}()
+
+	select {
+	case code := <-exits:
+		if code != 3 {
+			t.Fatalf("exit code %d, want 3", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("re-entrant fatal entry didn't exit")
+	}
+	<-done
+}
+
+func TestFatalExitCode(t *testing.T) {
+	if path := os.Getenv("LOG_TEST_FATAL_OUTPUT"); path != "" {
+		opts := NewOptions()
+		opts.Format = jsonFormat
+		opts.OutputPaths = []string{path}
+		opts.FatalExitCode = 42
+		New(opts).Fatal("fatal entry")
+
+		return
+	}
+
+	output := t.TempDir() + "/fatal.log"
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalExitCode$")
+	cmd.Env = append(os.Environ(), "LOG_TEST_FATAL_OUTPUT="+output)
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 42 {
+		t.Fatalf("fatal log exited with %v, want exit status 42", err)
+	}
+
+	entries := readEntries(t, output)
+	if len(entries) != 1 || entries[0]["message"] != "fatal entry" {
+		t.Fatalf("fatal entry not flushed: %v", entries)
+	}
+}
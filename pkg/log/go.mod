@@ -5,18 +5,29 @@ go 1.18
 require (
 	github.com/spf13/pflag v1.0.5
 	github.com/uber/jaeger-client-go v2.30.0+incompatible
-	go.uber.org/zap v1.21.0
+	go.opentelemetry.io/proto/otlp v1.0.0
+	go.uber.org/zap v1.23.0
+	golang.org/x/term v0.16.0
+	google.golang.org/grpc v1.56.3
+	google.golang.org/protobuf v1.31.0
 )
 
 require (
 	github.com/HdrHistogram/hdrhistogram-go v1.1.2 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.16.0 // indirect
+	github.com/stretchr/objx v0.4.0 // indirect
 	github.com/uber/jaeger-lib v2.4.1+incompatible // indirect
+	golang.org/x/net v0.20.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20230530153820-e85fd2cbaebc // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230530153820-e85fd2cbaebc // indirect
 )
 
 require (
 	github.com/gofrs/uuid v4.2.0+incompatible
 	github.com/opentracing/opentracing-go v1.2.0
-	github.com/stretchr/testify v1.8.0 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
-	go.uber.org/multierr v1.6.0 // indirect
+	go.uber.org/multierr v1.6.0
 )
@@ -1,5 +1,12 @@
 package log
 
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
 const (
 	// DebugLevel logs are typically voluminous, and are usually disabled in
 	// production.
@@ -20,3 +27,124 @@ const (
 	// FatalLevel logs a message, then calls os.Exit(1).
 	FatalLevel
 )
+
+const (
+	// TraceLevel logs are even more detailed than debug ones.
+	TraceLevel = DebugLevel - 1
+	// NoticeLevel logs are normal but significant events, ranked between
+	// InfoLevel and WarnLevel. zap has no value between them, so its value
+	// is below TraceLevel and the numeric comparisons rank it lowest:
+	//   - compare levels with NewLevelEnabler rather than numerically
+	//   - the zap cores given to NewLogger must be enabled with
+	//     NewLevelEnabler, a zap level enabler at info level drops them
+	//   - zap samplers don't sample the notice and trace entries, which
+	//     are outside of their levels
+	NoticeLevel = TraceLevel - 1
+
+	// minLevel is the lowest value of the levels
+	minLevel = NoticeLevel
+)
+
+// levelRank orders the levels, NoticeLevel included
+func levelRank(l Level) int {
+	if l == NoticeLevel {
+		return 2*int(InfoLevel) + 1
+	}
+
+	return 2 * int(l)
+}
+
+// levelEnabler enables the levels ranked at least as its own
+type levelEnabler Level
+
+func (e levelEnabler) Enabled(l zapcore.Level) bool {
+	return levelRank(l) >= levelRank(Level(e))
+}
+
+// NewLevelEnabler enables min and the levels above it
+func NewLevelEnabler(min Level) zapcore.LevelEnabler {
+	return levelEnabler(min)
+}
+
+// ParseLevel parses a level name, case insensitive
+func ParseLevel(text string) (Level, error) {
+	switch strings.ToLower(text) {
+	case "trace":
+		return TraceLevel, nil
+	case "notice":
+		return NoticeLevel, nil
+	}
+
+	var l Level
+	if err := l.UnmarshalText([]byte(strings.ToLower(text))); err != nil {
+		return l, fmt.Errorf("unrecognized level: %q", text)
+	}
+
+	return l, nil
+}
+
+// LevelString returns the lower case name of a level
+func LevelString(l Level) string {
+	switch l {
+	case TraceLevel:
+		return "trace"
+	case NoticeLevel:
+		return "notice"
+	}
+
+	return l.String()
+}
+
+// SyslogSeverity maps a level to its syslog severity (RFC 5424)
+func SyslogSeverity(l Level) int {
+	switch l {
+	case TraceLevel, DebugLevel:
+		return 7 // debug
+	case InfoLevel:
+		return 6 // informational
+	case NoticeLevel:
+		return 5 // notice
+	case WarnLevel:
+		return 4 // warning
+	case ErrorLevel:
+		return 3 // error
+	case DPanicLevel:
+		return 2 // critical
+	case PanicLevel:
+		return 1 // alert
+	case FatalLevel:
+		return 0 // emergency
+	}
+
+	return 7
+}
+
+// capitalLevelEncoder serializes a level to an all-caps string,
+// TRACE and NOTICE included
+func capitalLevelEncoder(l Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(strings.ToUpper(LevelString(l)))
+}
+
+// levelColors are the ANSI colors of the levels, the same as zap's
+var levelColors = map[Level]int{
+	TraceLevel:  36, // cyan
+	DebugLevel:  35, // magenta
+	InfoLevel:   34, // blue
+	NoticeLevel: 32, // green
+	WarnLevel:   33, // yellow
+	ErrorLevel:  31, // red
+	DPanicLevel: 31,
+	PanicLevel:  31,
+	FatalLevel:  31,
+}
+
+// capitalColorLevelEncoder serializes a level to an all-caps string with
+// its ANSI color
+func capitalColorLevelEncoder(l Level, enc zapcore.PrimitiveArrayEncoder) {
+	color, ok := levelColors[l]
+	if !ok {
+		color = 31
+	}
+
+	enc.AppendString(fmt.Sprintf("\x1b[%dm%s\x1b[0m", color, strings.ToUpper(LevelString(l))))
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levels are the levels from the lowest to the highest
+var levels = []Level{
+	TraceLevel, DebugLevel, InfoLevel, NoticeLevel, WarnLevel, ErrorLevel, DPanicLevel, PanicLevel, FatalLevel,
+}
+
+func TestParseLevel(t *testing.T) {
+	names := []string{"trace", "debug", "info", "notice", "warn", "error", "dpanic", "panic", "fatal"}
+	for i, name := range names {
+		for _, text := range []string{name, strings.ToUpper(name)} {
+			l, err := ParseLevel(text)
+			if err != nil || l != levels[i] {
+				t.Errorf("ParseLevel(%q) = %v, %v, want %v", text, l, err, levels[i])
+			}
+		}
+
+		if got := LevelString(levels[i]); got != name {
+			t.Errorf("LevelString(%v) = %q, want %q", levels[i], got, name)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("unknown level parsed")
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	want := []int{7, 7, 6, 5, 4, 3, 2, 1, 0}
+	for i, l := range levels {
+		if got := SyslogSeverity(l); got != want[i] {
+			t.Errorf("SyslogSeverity(%s) = %d, want %d", LevelString(l), got, want[i])
+		}
+	}
+}
+
+func TestLevelOrdering(t *testing.T) {
+	sorted := append([]Level(nil), levels...)
+	sort.Slice(sorted, func(i, j int) bool { return levelRank(sorted[i]) < levelRank(sorted[j]) })
+	for i := range levels {
+		if sorted[i] != levels[i] {
+			t.Fatalf("levels ranked %v, want %v", sorted, levels)
+		}
+	}
+
+	for i, min := range levels {
+		enabler := NewLevelEnabler(min)
+		for j, l := range levels {
+			if got := enabler.Enabled(l); got != (j >= i) {
+				t.Errorf("%s enabled at %s: %v, want %v", LevelString(l), LevelString(min), got, j >= i)
+			}
+		}
+	}
+}
+
+// TestNoticeNumericLimitation documents the ranking of NoticeLevel by zap,
+// which compares the levels numerically
+func TestNoticeNumericLimitation(t *testing.T) {
+	if zapcore.InfoLevel.Enabled(NoticeLevel) {
+		t.Fatal("zap enables notice at info level, update the NoticeLevel doc")
+	}
+
+	// the cores enabled with NewLevelEnabler log them
+	core, observed := newObservedCore(NewLevelEnabler(InfoLevel))
+	l := NewLogger(zap.New(core))
+	l.Notice("notice")
+	l.Debug("debug")
+
+	if observed() != 1 {
+		t.Fatalf("%d entries, want the notice one", observed())
+	}
+}
+
+// newObservedCore returns a core counting the entries it writes
+func newObservedCore(enab zapcore.LevelEnabler) (zapcore.Core, func() int) {
+	var count int
+	core := zapcore.RegisterHooks(
+		zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{}), zapcore.AddSync(io.Discard), enab),
+		func(zapcore.Entry) error {
+			count++
+
+			return nil
+		},
+	)
+
+	return core, func() int { return count }
+}
@@ -0,0 +1,517 @@
+package log
+
+import (
+	"encoding/json"
+	"time"
+	"unicode/utf8"
+
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// TruncatedMarker ends the values truncated by the size limits, it is
+// also the last element of truncated arrays and replaces the objects
+// nested too deeply
+const TruncatedMarker = "...[truncated]"
+
+// originalLengthSuffix is the suffix of the key of the field holding the
+// original length of a truncated value
+const originalLengthSuffix = "_original_length"
+
+// sizeLimits caps the size of the entries, 0 means no limit
+type sizeLimits struct {
+	message int
+	field   int
+	array   int
+	depth   int
+	entry   int
+}
+
+// newSizeLimits returns the limits of opts, nil if there are none
+func newSizeLimits(opts *Options) *sizeLimits {
+	s := &sizeLimits{
+		message: opts.MaxMessageLength,
+		field:   opts.MaxFieldLength,
+		array:   opts.MaxArrayLength,
+		depth:   opts.MaxNestingDepth,
+		entry:   opts.MaxEntrySize,
+	}
+	if *s == (sizeLimits{}) {
+		return nil
+	}
+
+	return s
+}
+
+// truncate cuts s to max bytes, on a rune boundary, and adds the marker
+func truncate(s string, max int) (string, bool) {
+	if max <= 0 || len(s) <= max {
+		return s, false
+	}
+
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+
+	return s[:max] + TruncatedMarker, true
+}
+
+func (s *sizeLimits) addString(enc zapcore.ObjectEncoder, key, value string) {
+	if truncated, ok := truncate(value, s.field); ok {
+		enc.AddString(key, truncated)
+		enc.AddInt(key+originalLengthSuffix, len(value))
+
+		return
+	}
+	enc.AddString(key, value)
+}
+
+func (s *sizeLimits) addBytes(enc zapcore.ObjectEncoder, key string, value []byte, add func(string, []byte)) {
+	if s.field > 0 && len(value) > s.field {
+		truncated, _ := truncate(string(value), s.field)
+		enc.AddString(key, truncated)
+		enc.AddInt(key+originalLengthSuffix, len(value))
+
+		return
+	}
+	add(key, value)
+}
+
+func (s *sizeLimits) addArray(enc zapcore.ObjectEncoder, depth int, key string, arr zapcore.ArrayMarshaler) error {
+	if s.depth > 0 && depth+1 > s.depth {
+		enc.AddString(key, TruncatedMarker)
+
+		return nil
+	}
+
+	la := &limitArray{ArrayMarshaler: arr, limits: s, depth: depth + 1}
+	if err := enc.AddArray(key, la); err != nil {
+		return err
+	}
+	if la.truncated {
+		enc.AddInt(key+originalLengthSuffix, la.length)
+	}
+
+	return nil
+}
+
+func (s *sizeLimits) addObject(enc zapcore.ObjectEncoder, depth int, key string, obj zapcore.ObjectMarshaler) error {
+	if s.depth > 0 && depth+1 > s.depth {
+		enc.AddString(key, TruncatedMarker)
+
+		return nil
+	}
+
+	return enc.AddObject(key, &limitObject{ObjectMarshaler: obj, limits: s, depth: depth + 1})
+}
+
+// addReflected encodes the value to measure it, the field limit applies to
+// its JSON encoding
+func (s *sizeLimits) addReflected(enc zapcore.ObjectEncoder, key string, value interface{}) error {
+	if s.field <= 0 {
+		return enc.AddReflected(key, value)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return enc.AddReflected(key, value)
+	}
+
+	if truncated, ok := truncate(string(data), s.field); ok {
+		enc.AddString(key, truncated)
+		enc.AddInt(key+originalLengthSuffix, len(data))
+
+		return nil
+	}
+
+	return enc.AddReflected(key, json.RawMessage(data))
+}
+
+// spanFields applies the message and field limits to the fields of a span
+// log, the message is the event field
+func (s *sizeLimits) spanFields(fields []otlog.Field) []otlog.Field {
+	limited := make([]otlog.Field, 0, len(fields))
+	for _, f := range fields {
+		max := s.field
+		if f.Key() == "event" {
+			max = s.message
+		}
+
+		var value string
+		switch v := f.Value().(type) {
+		case string:
+			value = v
+		case []byte:
+			value = string(v)
+		default:
+			limited = append(limited, f)
+
+			continue
+		}
+
+		if truncated, ok := truncate(value, max); ok {
+			limited = append(limited, otlog.String(f.Key(), truncated), otlog.Int(f.Key()+originalLengthSuffix, len(value)))
+		} else {
+			limited = append(limited, f)
+		}
+	}
+
+	return limited
+}
+
+// limitEncoder applies the size limits to the entries of an encoder
+type limitEncoder struct {
+	zapcore.Encoder
+	limits *sizeLimits
+	// messageKey is the MessageKey of the encoder config, the original
+	// length of a truncated message is added under messageKey+"_original_length"
+	messageKey string
+}
+
+func newLimitEncoder(enc zapcore.Encoder, limits *sizeLimits, messageKey string) zapcore.Encoder {
+	if limits == nil {
+		return enc
+	}
+
+	return &limitEncoder{Encoder: enc, limits: limits, messageKey: messageKey}
+}
+
+func (e *limitEncoder) AddString(key, value string) {
+	e.limits.addString(e.Encoder, key, value)
+}
+
+func (e *limitEncoder) AddByteString(key string, value []byte) {
+	e.limits.addBytes(e.Encoder, key, value, e.Encoder.AddByteString)
+}
+
+func (e *limitEncoder) AddBinary(key string, value []byte) {
+	e.limits.addBytes(e.Encoder, key, value, e.Encoder.AddBinary)
+}
+
+func (e *limitEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	return e.limits.addArray(e.Encoder, 0, key, arr)
+}
+
+func (e *limitEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	return e.limits.addObject(e.Encoder, 0, key, obj)
+}
+
+func (e *limitEncoder) AddReflected(key string, value interface{}) error {
+	return e.limits.addReflected(e.Encoder, key, value)
+}
+
+func (e *limitEncoder) Clone() zapcore.Encoder {
+	return &limitEncoder{Encoder: e.Encoder.Clone(), limits: e.limits, messageKey: e.messageKey}
+}
+
+// EncodeEntry adds the fields through the limits, the wrapped encoder
+// would add them directly otherwise. An entry still larger than the entry
+// limit is encoded again without the fields of the call, the fields added
+// by With are kept.
+func (e *limitEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	message := ent.Message
+	ent.Message, _ = truncate(message, e.limits.message)
+
+	c := e.Encoder.Clone()
+	e.addMessageLength(c, ent.Message, message)
+	lc := &limitEncoder{Encoder: c, limits: e.limits, messageKey: e.messageKey}
+	for _, f := range fields {
+		f.AddTo(lc)
+	}
+
+	buf, err := c.EncodeEntry(ent, nil)
+	freeEncoder(c)
+	if err != nil || e.limits.entry <= 0 || buf.Len() <= e.limits.entry {
+		return buf, err
+	}
+
+	size := buf.Len()
+	buf.Free()
+
+	// the message and the stack share what is left of the entry
+	ent.Message, _ = truncate(ent.Message, e.limits.entry/4)
+	ent.Stack, _ = truncate(ent.Stack, e.limits.entry/4)
+
+	c = e.Encoder.Clone()
+	defer freeEncoder(c)
+	e.addMessageLength(c, ent.Message, message)
+
+	return c.EncodeEntry(ent, []zapcore.Field{
+		zap.String("fields", TruncatedMarker),
+		zap.Int("entry"+originalLengthSuffix, size),
+	})
+}
+
+// freeEncoder returns the buffer of a clone encoding a single entry to its
+// pool
+func freeEncoder(enc zapcore.Encoder) {
+	if p, ok := enc.(pooledEncoder); ok {
+		p.free()
+	}
+}
+
+// addMessageLength adds the original length of the message if it was
+// truncated
+func (e *limitEncoder) addMessageLength(enc zapcore.Encoder, message, original string) {
+	if message != original && e.messageKey != "" {
+		enc.AddInt(e.messageKey+originalLengthSuffix, len(original))
+	}
+}
+
+// limitObject applies the limits to the fields of a nested object
+type limitObject struct {
+	zapcore.ObjectMarshaler
+	limits *sizeLimits
+	depth  int
+}
+
+func (o *limitObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.ObjectMarshaler.MarshalLogObject(&limitObjectEncoder{ObjectEncoder: enc, limits: o.limits, depth: o.depth})
+}
+
+type limitObjectEncoder struct {
+	zapcore.ObjectEncoder
+	limits *sizeLimits
+	depth  int
+}
+
+func (e *limitObjectEncoder) AddString(key, value string) {
+	e.limits.addString(e.ObjectEncoder, key, value)
+}
+
+func (e *limitObjectEncoder) AddByteString(key string, value []byte) {
+	e.limits.addBytes(e.ObjectEncoder, key, value, e.ObjectEncoder.AddByteString)
+}
+
+func (e *limitObjectEncoder) AddBinary(key string, value []byte) {
+	e.limits.addBytes(e.ObjectEncoder, key, value, e.ObjectEncoder.AddBinary)
+}
+
+func (e *limitObjectEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	return e.limits.addArray(e.ObjectEncoder, e.depth, key, arr)
+}
+
+func (e *limitObjectEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	return e.limits.addObject(e.ObjectEncoder, e.depth, key, obj)
+}
+
+func (e *limitObjectEncoder) AddReflected(key string, value interface{}) error {
+	return e.limits.addReflected(e.ObjectEncoder, key, value)
+}
+
+// limitArray applies the limits to the elements of an array, it records
+// whether the array was truncated and its length
+type limitArray struct {
+	zapcore.ArrayMarshaler
+	limits    *sizeLimits
+	depth     int
+	length    int
+	truncated bool
+}
+
+func (a *limitArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	la := &limitArrayEncoder{ArrayEncoder: enc, limits: a.limits, depth: a.depth}
+	if err := a.ArrayMarshaler.MarshalLogArray(la); err != nil {
+		return err
+	}
+
+	a.length = la.length
+	if a.limits.array > 0 && la.length > a.limits.array {
+		a.truncated = true
+		enc.AppendString(TruncatedMarker)
+	}
+
+	return nil
+}
+
+// limitArrayEncoder drops the elements beyond the array limit
+type limitArrayEncoder struct {
+	zapcore.ArrayEncoder
+	limits *sizeLimits
+	depth  int
+	length int
+}
+
+// keep counts an element and reports whether it is within the limit
+func (e *limitArrayEncoder) keep() bool {
+	e.length++
+
+	return e.limits.array <= 0 || e.length <= e.limits.array
+}
+
+func (e *limitArrayEncoder) AppendBool(v bool) {
+	if e.keep() {
+		e.ArrayEncoder.AppendBool(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendByteString(v []byte) {
+	if e.keep() {
+		if e.limits.field > 0 && len(v) > e.limits.field {
+			truncated, _ := truncate(string(v), e.limits.field)
+			e.ArrayEncoder.AppendString(truncated)
+
+			return
+		}
+		e.ArrayEncoder.AppendByteString(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendComplex128(v complex128) {
+	if e.keep() {
+		e.ArrayEncoder.AppendComplex128(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendComplex64(v complex64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendComplex64(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendFloat64(v float64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendFloat64(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendFloat32(v float32) {
+	if e.keep() {
+		e.ArrayEncoder.AppendFloat32(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendInt(v int) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendInt64(v int64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt64(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendInt32(v int32) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt32(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendInt16(v int16) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt16(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendInt8(v int8) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt8(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendString(v string) {
+	if e.keep() {
+		truncated, _ := truncate(v, e.limits.field)
+		e.ArrayEncoder.AppendString(truncated)
+	}
+}
+
+func (e *limitArrayEncoder) AppendUint(v uint) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendUint64(v uint64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint64(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendUint32(v uint32) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint32(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendUint16(v uint16) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint16(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendUint8(v uint8) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint8(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendUintptr(v uintptr) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUintptr(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendDuration(v time.Duration) {
+	if e.keep() {
+		e.ArrayEncoder.AppendDuration(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendTime(v time.Time) {
+	if e.keep() {
+		e.ArrayEncoder.AppendTime(v)
+	}
+}
+
+func (e *limitArrayEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	if !e.keep() {
+		return nil
+	}
+
+	if e.limits.depth > 0 && e.depth+1 > e.limits.depth {
+		e.ArrayEncoder.AppendString(TruncatedMarker)
+
+		return nil
+	}
+
+	return e.ArrayEncoder.AppendArray(&limitArray{ArrayMarshaler: arr, limits: e.limits, depth: e.depth + 1})
+}
+
+func (e *limitArrayEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	if !e.keep() {
+		return nil
+	}
+
+	if e.limits.depth > 0 && e.depth+1 > e.limits.depth {
+		e.ArrayEncoder.AppendString(TruncatedMarker)
+
+		return nil
+	}
+
+	return e.ArrayEncoder.AppendObject(&limitObject{ObjectMarshaler: obj, limits: e.limits, depth: e.depth + 1})
+}
+
+func (e *limitArrayEncoder) AppendReflected(v interface{}) error {
+	if !e.keep() {
+		return nil
+	}
+
+	if e.limits.field > 0 {
+		if data, err := json.Marshal(v); err == nil {
+			if truncated, ok := truncate(string(data), e.limits.field); ok {
+				e.ArrayEncoder.AppendString(truncated)
+
+				return nil
+			}
+
+			return e.ArrayEncoder.AppendReflected(json.RawMessage(data))
+		}
+	}
+
+	return e.ArrayEncoder.AppendReflected(v)
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// encodeLimited encodes an entry of msg and fields with a JSON encoder of
+// limits holding the context fields
+func encodeLimited(t *testing.T, limits *sizeLimits, context []Field, msg string, fields ...Field) map[string]interface{} {
+	t.Helper()
+
+	enc := newLimitEncoder(zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"}), limits, "msg").Clone()
+	for _, f := range context {
+		f.AddTo(enc)
+	}
+
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: msg}, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buf.Free()
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid entry %q: %v", buf.String(), err)
+	}
+
+	return entry
+}
+
+func TestLimitMessage(t *testing.T) {
+	entry := encodeLimited(t, &sizeLimits{message: 4}, nil, "0123456789")
+
+	if entry["msg"] != "0123"+TruncatedMarker || entry["msg"+originalLengthSuffix] != float64(10) {
+		t.Fatalf("entry %v, want the message truncated", entry)
+	}
+}
+
+func TestLimitOversizedEntry(t *testing.T) {
+	limits := &sizeLimits{message: 200, entry: 256}
+	context := []Field{zap.String("requestID", "r1")}
+	entry := encodeLimited(t, limits, context, strings.Repeat("m", 300), zap.String("payload", strings.Repeat("p", 1024)))
+
+	// the fields of the call are dropped, the context fields are kept
+	if entry["requestID"] != "r1" || entry["payload"] != nil || entry["fields"] != TruncatedMarker {
+		t.Fatalf("entry %v, want the context fields only", entry)
+	}
+	if msg, _ := entry["msg"].(string); msg != strings.Repeat("m", 64)+TruncatedMarker {
+		t.Fatalf("message %q, want it truncated to a quarter of the entry", msg)
+	}
+	if entry["msg"+originalLengthSuffix] != float64(300) {
+		t.Fatalf("entry %v, want the original length of the message", entry)
+	}
+	if size, _ := entry["entry"+originalLengthSuffix].(float64); size <= 1024 {
+		t.Fatalf("entry %v, want the original size of the entry", entry)
+	}
+}
+
+func TestLimitEntryWithinSize(t *testing.T) {
+	entry := encodeLimited(t, &sizeLimits{entry: 1024}, nil, "hello", zap.String("payload", "p"))
+
+	if entry["payload"] != "p" || entry["fields"] != nil {
+		t.Fatalf("entry %v, want it unchanged", entry)
+	}
+}
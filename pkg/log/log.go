@@ -2,17 +2,24 @@ package log
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
 	tag "github.com/opentracing/opentracing-go/ext"
 	"github.com/uber/jaeger-client-go"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 type Logger interface {
+	Trace(msg string, fields ...Field)
+	Tracef(format string, v ...interface{})
+	Tracew(msg string, keysAndValues ...interface{})
+
 	Debug(msg string, fields ...Field)
 	Debugf(format string, v ...interface{})
 	Debugw(msg string, keysAndValues ...interface{})
@@ -21,6 +28,10 @@ type Logger interface {
 	Infof(format string, v ...interface{})
 	Infow(msg string, keysAndValues ...interface{})
 
+	Notice(msg string, fields ...Field)
+	Noticef(format string, v ...interface{})
+	Noticew(msg string, keysAndValues ...interface{})
+
 	Warn(msg string, fields ...Field)
 	Warnf(format string, v ...interface{})
 	Warnw(msg string, keysAndValues ...interface{})
@@ -29,6 +40,10 @@ type Logger interface {
 	Errorf(format string, v ...interface{})
 	Errorw(msg string, keysAndValues ...interface{})
 
+	DPanic(msg string, fields ...Field)
+	DPanicf(format string, v ...interface{})
+	DPanicw(msg string, keysAndValues ...interface{})
+
 	Panic(msg string, fields ...Field)
 	Panicf(format string, v ...interface{})
 	Panicw(msg string, keysAndValues ...interface{})
@@ -37,10 +52,22 @@ type Logger interface {
 	Fatalf(format string, v ...interface{})
 	Fatalw(msg string, keysAndValues ...interface{})
 
+	// Enabled reports whether entries at lvl are logged. The calls through
+	// Logger allocate their variadic arguments even for disabled levels,
+	// Enabled avoids it on hot paths.
+	Enabled(lvl Level) bool
+
+	// V returns an InfoLogger for the verbosity level
+	V(level int) InfoLogger
+
 	WithValues(keysAndValue ...interface{}) Logger
 
 	WithName(string) Logger
 
+	// WithCallerSkip returns a child logger skipping skip more frames to
+	// report the caller, for the wrappers of Logger
+	WithCallerSkip(skip int) Logger
+
 	C(ctx context.Context) Logger
 
 	WithContext(ctx context.Context) context.Context
@@ -48,46 +75,313 @@ type Logger interface {
 	Flush()
 }
 
+// zapLogger is immutable once created. Every method deriving a new logger
+// (WithName, WithValues, C, J) works on a copy, so loggers can be shared
+// between goroutines freely.
 type zapLogger struct {
-	zapLogger  *zap.Logger
-	span       opentracing.Span
-	spanFields []Field
-	isSpan     bool
+	zapLogger *zap.Logger
+	// sugar is the sugared zapLogger, cached as Sugar allocates
+	sugar *zap.SugaredLogger
+	// name is the full name of the logger
+	name string
+	// verbosity decides the V levels enabled by name
+	verbosity *verbosity
+	// vLoggers caches the loggers of V, each derived logger has its own
+	vLoggers *vLoggers
+	// commonFields are the context keys C copies into the log fields
+	commonFields []string
+	// span is set by J, log entries are also recorded on it
+	span opentracing.Span
+	// ring keeps the recent entries, nil if disabled
+	ring *ringBuffer
+	// sinks are the guarded output sinks, nil for loggers not built by New
+	sinks []*guardedSink
+	// tenants routes the entries of the tenants, nil if disabled
+	tenants *tenantRouter
+	// limits cap the size of the entries, also those recorded on span
+	limits *sizeLimits
+	// disableStacktrace and stacktraceMaxDepth apply to the stack traces
+	// recorded whatever the stacktrace level, such as by Recover
+	disableStacktrace  bool
+	stacktraceMaxDepth int
+	// bufferLimits of the loggers created by WithBuffering
+	bufferLimits bufferLimits
+	// exporter exports the entries to OTLP, nil if disabled
+	exporter *OTLPExporter
+	// closer closes the sinks of New, shared by the derived loggers
+	closer *closer
+}
+
+// closer closes the resources of a logger once
+type closer struct {
+	once  sync.Once
+	funcs []func() error
+	err   error
+}
+
+func (c *closer) add(fn func() error) {
+	c.funcs = append(c.funcs, fn)
+}
+
+func (c *closer) close() error {
+	c.once.Do(func() {
+		for _, fn := range c.funcs {
+			c.err = multierr.Append(c.err, fn())
+		}
+	})
+
+	return c.err
+}
+
+// bufferLimits caps the memory held by a request buffer, 0 means no limit
+type bufferLimits struct {
+	maxEntries int
+	maxBytes   int
 }
 
 var (
-	std          = New(NewOptions())
-	mu           sync.Mutex
-	commonFields []string
+	std = New(NewOptions())
+	mu  sync.Mutex
 )
 
-func ResetDefault(opts *Options) {
+// ResetDefault replaces the default logger and returns the previous one,
+// flushed. The loggers derived from it still write to its sinks, the
+// caller closes it once they are no longer used.
+func ResetDefault(opts *Options) *zapLogger {
 	mu.Lock()
 	defer mu.Unlock()
+
+	previous := std
 	std = New(opts)
+	previous.Flush()
+
+	return previous
 }
 
 // New craetes logger by customized opts
 func New(opts *Options) *zapLogger {
-	if opts != nil {
+	if opts == nil {
 		// default options
 		opts = NewOptions()
 	}
 
-	var zapLevel zapcore.Level
-	if err := zapLevel.UnmarshalText([]byte(opts.Level)); err != nil {
-		zapLevel = zapcore.InfoLevel
+	// the auto format is resolved once, the encoders see the chosen one
+	format, autoReason := resolveFormat(opts.Format)
+	if autoReason != "" {
+		resolved := *opts
+		resolved.Format = format
+		opts = &resolved
+	}
+
+	zapLevel, err := ParseLevel(opts.Level)
+	if err != nil {
+		zapLevel = InfoLevel
+	}
+
+	vb, err := newVerbosity(opts.Verbosity, opts.VModule)
+	if err != nil {
+		vb = &verbosity{v: opts.Verbosity}
+	}
+
+	encoderConfig := EncoderConfig(opts)
+
+	// the sinks are opened here rather than by zap.Config so that each
+	// applies its failure policy
+	sinks, err := openSinks(opts)
+	if err != nil {
+		panic(err)
+	}
+
+	errSink, closeErrSink, err := zap.Open(opts.errorOutputPaths()...)
+	if err != nil {
+		panic(err)
+	}
+
+	// the level of the logger is applied by levelCore
+	limits := newSizeLimits(opts)
+	encoder := newLimitEncoder(newEncoder(opts, encoderConfig), limits, encoderConfig.MessageKey)
+	core := zapcore.NewCore(encoder, writeSyncer(sinks), minLevel)
+
+	// the split files share the encoder and the levels of the output paths
+	if opts.LevelOutputPath != "" {
+		splitCore, splitSinks, err := newSplitCore(opts, encoder)
+		if err != nil {
+			panic(err)
+		}
+
+		core = zapcore.NewTee(core, splitCore)
+		sinks = append(sinks, splitSinks...)
+	}
+
+	closers := &closer{}
+	for _, s := range sinks {
+		closers.add(s.Close)
+	}
+
+	fatal := newFatalHook(opts)
+	buildOpts := []zap.Option{
+		zap.ErrorOutput(errSink),
+		zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(c, time.Second, 100, 100)
+		}),
+		zap.AddCallerSkip(1 + opts.CallerSkip),
+		zap.WithFatalHook(fatal),
+	}
+
+	// the exports are filtered by the level of the logger, teed before it
+	var exporter *OTLPExporter
+	if opts.OTLPEndpoint != "" {
+		exporter, err = newOptionsExporter(opts, errSink)
+		if err != nil {
+			panic(err)
+		}
+		closers.add(func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), otlpSyncTimeout)
+			defer cancel()
+
+			return exporter.Shutdown(ctx)
+		})
+
+		buildOpts = append(buildOpts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(c, exporter.Core(minLevel))
+		}))
+	}
+
+	buildOpts = append(buildOpts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return newLevelCore(c, levelEnabler(zapLevel))
+	}))
+
+	// the tenants may log below the logger level, so they are routed
+	// around the level filtering
+	var tenants *tenantRouter
+	if opts.TenantField != "" {
+		tenants, err = newTenantRouter(opts, zapLevel, errSink)
+		if err != nil {
+			panic(err)
+		}
+		closers.add(tenants.close)
+
+		buildOpts = append(buildOpts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return newTenantCore(c, encoder, opts.TenantField, tenants)
+		}))
+	}
+
+	// the ring buffer records entries below the sinks level, so it is teed
+	// after sampling and level filtering of the sinks core
+	var ring *ringBuffer
+	if opts.RingBufferSize > 0 {
+		ringLevel, err := ParseLevel(opts.RingBufferLevel)
+		if err != nil {
+			ringLevel = DebugLevel
+		}
+
+		ringEncoderConfig := encoderConfig
+		ringEncoderConfig.EncodeLevel = capitalLevelEncoder
+		ring = newRingBuffer(opts.RingBufferSize, opts.CrashFile, opts.Development)
+		rc := newRingCore(ring, zapcore.NewJSONEncoder(ringEncoderConfig), levelEnabler(ringLevel))
+		buildOpts = append(buildOpts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return newRingTee(c, rc)
+		}))
+	}
+
+	// the stack traces are taken by stackCore rather than zap, so that
+	// they can be filtered
+	if !opts.DisableStacktrace {
+		stackLevel := levelEnabler(stacktraceLevel(opts))
+		buildOpts = append(buildOpts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return newStackCore(c, stackLevel, opts.StacktraceMaxDepth)
+		}))
+	}
+
+	if opts.Development {
+		buildOpts = append(buildOpts, zap.Development())
+	}
+	if !opts.DisableCaller {
+		buildOpts = append(buildOpts, zap.AddCaller())
+	}
+
+	// AddCallerSkip(1) to skip logfile info
+	l := zap.New(core, buildOpts...)
+	fatal.sync = l.Sync
+
+	l = l.Named(opts.Name)
+	logger := &zapLogger{
+		zapLogger:    l,
+		sugar:        l.Sugar(),
+		name:         opts.Name,
+		verbosity:    vb,
+		vLoggers:     &vLoggers{},
+		commonFields: append([]string(nil), opts.CommonFields...),
+		ring:         ring,
+		sinks:        sinks,
+		tenants:      tenants,
+		limits:       limits,
+		bufferLimits: bufferLimits{
+			maxEntries: opts.RequestBufferMaxEntries,
+			maxBytes:   opts.RequestBufferMaxBytes,
+		},
+		disableStacktrace:  opts.DisableStacktrace,
+		stacktraceMaxDepth: opts.StacktraceMaxDepth,
+		exporter:           exporter,
+		closer:             closers,
+	}
+
+	// the error outputs are closed last, the other closers may report to
+	// them
+	closers.add(func() error {
+		closeErrSink()
+
+		return nil
+	})
+
+	if autoReason != "" {
+		logger.logAutoFormat(opts, autoReason)
+	}
+
+	// zap.RedirectStdLog(l)
+
+	return logger
+}
+
+// NewEncoder returns the encoder New uses for opts, without the size
+// limits. Log viewers use it to render entries the same way.
+func NewEncoder(opts *Options) zapcore.Encoder {
+	format, _ := resolveFormat(opts.Format)
+	resolved := *opts
+	resolved.Format = format
+
+	return newEncoder(&resolved, EncoderConfig(&resolved))
+}
+
+// newEncoder returns the encoder of the format, console by default and
+// the development console in development
+func newEncoder(opts *Options, cfg zapcore.EncoderConfig) zapcore.Encoder {
+	switch strings.ToLower(opts.Format) {
+	case jsonFormat:
+		return zapcore.NewJSONEncoder(cfg)
+	case binaryFormat:
+		return NewBinaryEncoder()
 	}
 
+	if opts.Development {
+		return newDevEncoder(colorEnabled(opts), outputTerminal(opts))
+	}
+
+	return zapcore.NewConsoleEncoder(cfg)
+}
+
+// EncoderConfig returns the zap encoder config New uses for opts.
+// Log viewers use it to render entries the same way.
+func EncoderConfig(opts *Options) zapcore.EncoderConfig {
 	// info -> INFO, error -> ERROR
-	encodeLevel := zapcore.CapitalLevelEncoder
-	// prints log with color when output to local
-	if opts.Format == consoleFormat && opts.EnableColor {
-		encodeLevel = zapcore.CapitalColorLevelEncoder
+	encodeLevel := capitalLevelEncoder
+	// prints log with color when output to a terminal
+	if colorEnabled(opts) {
+		encodeLevel = capitalColorLevelEncoder
 	}
 
 	// customized zap logger encoder config
-	encoderConfig := zapcore.EncoderConfig{
+	return zapcore.EncoderConfig{
 		MessageKey:    "message",
 		LevelKey:      "level",
 		TimeKey:       "timestamp",
@@ -97,58 +391,45 @@ func New(opts *Options) *zapLogger {
 		LineEnding:    zapcore.DefaultLineEnding,
 		EncodeLevel:   encodeLevel,
 		EncodeTime: func(t time.Time, pae zapcore.PrimitiveArrayEncoder) {
-			pae.AppendString(t.Format("2006-01-02 15:04:05.000"))
+			pae.AppendString(t.Format(TimeLayout))
 		},
 		EncodeDuration: func(d time.Duration, pae zapcore.PrimitiveArrayEncoder) {
 			pae.AppendFloat64(float64(d) / float64(time.Millisecond))
 		},
 		EncodeCaller: zapcore.ShortCallerEncoder,
 	}
-
-	// customized zap logger  config
-	loggerConfig := &zap.Config{
-		Level:             zap.NewAtomicLevelAt(zapLevel),
-		Development:       opts.Development,
-		DisableCaller:     opts.DisableCaller,
-		DisableStacktrace: opts.DisableStacktrace,
-		Sampling: &zap.SamplingConfig{
-			Initial:    100,
-			Thereafter: 100,
-		},
-		Encoding:         opts.Format,
-		EncoderConfig:    encoderConfig,
-		OutputPaths:      opts.OutputPaths,
-		ErrorOutputPaths: opts.ErrorOutputPaths,
-	}
-
-	var err error
-	// constructs a logger with loggerConfig and loggerEncoder
-	// AddCallerSkip(1) to skip logfile info
-	l, err := loggerConfig.Build(zap.AddStacktrace(zapcore.PanicLevel), zap.AddCallerSkip(1))
-	if err != nil {
-		panic(err)
-	}
-
-	logger := &zapLogger{
-		zapLogger: l.Named(opts.Name),
-	}
-
-	// zap.RedirectStdLog(l)
-
-	if len(opts.CommonFields) >= 0 {
-		commonFields = append(commonFields, opts.CommonFields...)
-	}
-
-	return logger
 }
 
-// NewLogger creates a new Logger with given zap logger
+// NewLogger creates a new Logger with given zap logger. Its cores must be
+// enabled with NewLevelEnabler to log the notice entries, see NoticeLevel.
 func NewLogger(l *zap.Logger) Logger {
 	return &zapLogger{
 		zapLogger: l,
+		sugar:     l.Sugar(),
+		vLoggers:  &vLoggers{},
+		// the zap logger is closed by its owner
+		closer: &closer{},
 	}
 }
 
+// derive returns a shallow copy of the logger writing to z. Fields of the
+// copy may be replaced, but the values they point to must never be
+// modified in place.
+func (l *zapLogger) derive(z *zap.Logger) *zapLogger {
+	c := *l
+	c.zapLogger = z
+	c.sugar = z.Sugar()
+	c.vLoggers = &vLoggers{}
+
+	return &c
+}
+
+// copyFields copies the fields of the log methods, so that the fields
+// passed don't escape to the heap and the disabled levels don't allocate
+func copyFields(fields []Field) []Field {
+	return append([]Field(nil), fields...)
+}
+
 // handleFields converts a bunch of arbitrary key-value pairs into Zap fields
 func handleFields(l *zap.Logger, args []interface{}, additional ...zap.Field) []zap.Field {
 	if len(args) == 0 {
@@ -179,7 +460,7 @@ func handleFields(l *zap.Logger, args []interface{}, additional ...zap.Field) []
 
 			break
 		}
-		fields = append(fields, zap.Any(keyStr, val))
+		fields = append(fields, Any(keyStr, val))
 		i += 2
 	}
 
@@ -190,9 +471,21 @@ func handleFields(l *zap.Logger, args []interface{}, additional ...zap.Field) []
 func WithName(s string) Logger { return std.WithName(s) }
 
 func (l *zapLogger) WithName(name string) Logger {
-	newLogger := l.zapLogger.Named(name)
+	lg := l.derive(l.zapLogger.Named(name))
+	// same as zap.Logger.Named
+	if l.name != "" && name != "" {
+		lg.name = l.name + "." + name
+	} else if name != "" {
+		lg.name = name
+	}
+
+	return lg
+}
+
+func WithCallerSkip(skip int) Logger { return std.WithCallerSkip(skip) }
 
-	return NewLogger(newLogger)
+func (l *zapLogger) WithCallerSkip(skip int) Logger {
+	return l.derive(l.zapLogger.WithOptions(zap.AddCallerSkip(skip)))
 }
 
 // WithValues creates a child logger and adds zap fileds to it
@@ -201,9 +494,16 @@ func WithValues(keysAndValues ...interface{}) Logger {
 }
 
 func (l *zapLogger) WithValues(keysAndValues ...interface{}) Logger {
-	newLogger := l.zapLogger.With(handleFields(l.zapLogger, keysAndValues)...)
+	return l.derive(l.zapLogger.With(handleFields(l.zapLogger, keysAndValues)...))
+}
+
+// Enabled reports whether the default logger logs entries at lvl
+func Enabled(lvl Level) bool {
+	return std.Enabled(lvl)
+}
 
-	return NewLogger(newLogger)
+func (l *zapLogger) Enabled(lvl Level) bool {
+	return l.zapLogger.Core().Enabled(lvl)
 }
 
 // Flush called before exiting
@@ -215,132 +515,380 @@ func (l *zapLogger) Flush() {
 	l.zapLogger.Sync()
 }
 
+// Close flushes and closes the default logger
+func Close() error {
+	return std.Close()
+}
+
+// Close flushes the logger and closes its sinks. The loggers derived from
+// it share the sinks, none of them can be used afterwards.
+func (l *zapLogger) Close() error {
+	l.Flush()
+
+	return l.closer.close()
+}
+
+// Trace method output trace level log.
+func Trace(msg string, fields ...Field) {
+	if !std.Enabled(TraceLevel) {
+		return
+	}
+
+	std.zapLogger.Log(TraceLevel, msg, copyFields(fields)...)
+}
+
+func (l *zapLogger) Trace(msg string, fields ...Field) {
+	if !l.Enabled(TraceLevel) {
+		return
+	}
+
+	if l.span != nil {
+		l.logToSpan("trace", msg, fields...)
+	}
+
+	l.zapLogger.Log(TraceLevel, msg, copyFields(fields)...)
+}
+
+// Tracef method output trace level log.
+func Tracef(format string, v ...interface{}) {
+	if !std.Enabled(TraceLevel) {
+		return
+	}
+
+	std.zapLogger.Log(TraceLevel, fmt.Sprintf(format, v...))
+}
+
+func (l *zapLogger) Tracef(format string, v ...interface{}) {
+	if !l.Enabled(TraceLevel) {
+		return
+	}
+
+	l.zapLogger.Log(TraceLevel, fmt.Sprintf(format, v...))
+}
+
+// Tracew method output trace level log.
+func Tracew(msg string, keysAndValues ...interface{}) {
+	if !std.Enabled(TraceLevel) {
+		return
+	}
+
+	std.zapLogger.Log(TraceLevel, msg, handleFields(std.zapLogger, keysAndValues)...)
+}
+
+func (l *zapLogger) Tracew(msg string, keysAndValues ...interface{}) {
+	if !l.Enabled(TraceLevel) {
+		return
+	}
+
+	l.zapLogger.Log(TraceLevel, msg, handleFields(l.zapLogger, keysAndValues)...)
+}
+
 // Debug method output debug level log.
 func Debug(msg string, fields ...Field) {
-	std.zapLogger.Debug(msg, fields...)
+	if !std.Enabled(DebugLevel) {
+		return
+	}
+
+	std.zapLogger.Debug(msg, copyFields(fields)...)
 }
 
 func (l *zapLogger) Debug(msg string, fields ...Field) {
-	if l.isSpan {
+	if !l.Enabled(DebugLevel) {
+		return
+	}
+
+	if l.span != nil {
 		l.logToSpan("debug", msg, fields...)
-		fields = append(l.spanFields, fields...)
 	}
 
-	l.zapLogger.Debug(msg, fields...)
+	l.zapLogger.Debug(msg, copyFields(fields)...)
 }
 
 // Debugf method output debug level log.
 func Debugf(format string, v ...interface{}) {
-	std.zapLogger.Sugar().Debugf(format, v...)
+	if !std.Enabled(DebugLevel) {
+		return
+	}
+
+	std.sugar.Debugf(format, v...)
 }
 
 func (l *zapLogger) Debugf(format string, v ...interface{}) {
-	l.zapLogger.Sugar().Debugf(format, v...)
+	if !l.Enabled(DebugLevel) {
+		return
+	}
+
+	l.sugar.Debugf(format, v...)
 }
 
 // Debugw method output debug level log.
 func Debugw(msg string, keysAndValues ...interface{}) {
-	std.zapLogger.Sugar().Debugw(msg, keysAndValues...)
+	if !std.Enabled(DebugLevel) {
+		return
+	}
+
+	std.zapLogger.Debug(msg, handleFields(std.zapLogger, keysAndValues)...)
 }
 
 func (l *zapLogger) Debugw(msg string, keysAndValues ...interface{}) {
-	l.zapLogger.Sugar().Debugw(msg, keysAndValues...)
+	if !l.Enabled(DebugLevel) {
+		return
+	}
+
+	l.zapLogger.Debug(msg, handleFields(l.zapLogger, keysAndValues)...)
 }
 
 // Info method output info level log.
 func Info(msg string, fields ...Field) {
-	std.zapLogger.Info(msg, fields...)
+	if !std.Enabled(InfoLevel) {
+		return
+	}
+
+	std.zapLogger.Info(msg, copyFields(fields)...)
 }
 
 func (l *zapLogger) Info(msg string, fields ...Field) {
-	if l.isSpan {
+	if !l.Enabled(InfoLevel) {
+		return
+	}
+
+	if l.span != nil {
 		l.logToSpan("info", msg, fields...)
-		fields = append(l.spanFields, fields...)
 	}
-	l.zapLogger.Info(msg, fields...)
+
+	l.zapLogger.Info(msg, copyFields(fields)...)
 }
 
 // Infof method output info level log.
 func Infof(format string, v ...interface{}) {
-	std.zapLogger.Sugar().Infof(format, v...)
+	if !std.Enabled(InfoLevel) {
+		return
+	}
+
+	std.sugar.Infof(format, v...)
 }
 
 func (l *zapLogger) Infof(format string, v ...interface{}) {
-	l.zapLogger.Sugar().Infof(format, v...)
+	if !l.Enabled(InfoLevel) {
+		return
+	}
+
+	l.sugar.Infof(format, v...)
 }
 
 // Infow method output info level log.
 func Infow(msg string, keysAndValues ...interface{}) {
-	std.zapLogger.Sugar().Infow(msg, keysAndValues...)
+	if !std.Enabled(InfoLevel) {
+		return
+	}
+
+	std.zapLogger.Info(msg, handleFields(std.zapLogger, keysAndValues)...)
 }
 
 func (l *zapLogger) Infow(msg string, keysAndValues ...interface{}) {
-	l.zapLogger.Sugar().Infow(msg, keysAndValues...)
+	if !l.Enabled(InfoLevel) {
+		return
+	}
+
+	l.zapLogger.Info(msg, handleFields(l.zapLogger, keysAndValues)...)
+}
+
+// Notice method output notice level log.
+func Notice(msg string, fields ...Field) {
+	if !std.Enabled(NoticeLevel) {
+		return
+	}
+
+	std.zapLogger.Log(NoticeLevel, msg, copyFields(fields)...)
+}
+
+func (l *zapLogger) Notice(msg string, fields ...Field) {
+	if !l.Enabled(NoticeLevel) {
+		return
+	}
+
+	if l.span != nil {
+		l.logToSpan("notice", msg, fields...)
+	}
+
+	l.zapLogger.Log(NoticeLevel, msg, copyFields(fields)...)
+}
+
+// Noticef method output notice level log.
+func Noticef(format string, v ...interface{}) {
+	if !std.Enabled(NoticeLevel) {
+		return
+	}
+
+	std.zapLogger.Log(NoticeLevel, fmt.Sprintf(format, v...))
+}
+
+func (l *zapLogger) Noticef(format string, v ...interface{}) {
+	if !l.Enabled(NoticeLevel) {
+		return
+	}
+
+	l.zapLogger.Log(NoticeLevel, fmt.Sprintf(format, v...))
+}
+
+// Noticew method output notice level log.
+func Noticew(msg string, keysAndValues ...interface{}) {
+	if !std.Enabled(NoticeLevel) {
+		return
+	}
+
+	std.zapLogger.Log(NoticeLevel, msg, handleFields(std.zapLogger, keysAndValues)...)
+}
+
+func (l *zapLogger) Noticew(msg string, keysAndValues ...interface{}) {
+	if !l.Enabled(NoticeLevel) {
+		return
+	}
+
+	l.zapLogger.Log(NoticeLevel, msg, handleFields(l.zapLogger, keysAndValues)...)
 }
 
 // Warn method output warning level log.
 func Warn(msg string, fields ...Field) {
-	std.zapLogger.Warn(msg, fields...)
+	if !std.Enabled(WarnLevel) {
+		return
+	}
+
+	std.zapLogger.Warn(msg, copyFields(fields)...)
 }
 
 func (l *zapLogger) Warn(msg string, fields ...Field) {
-	if l.isSpan {
+	if !l.Enabled(WarnLevel) {
+		return
+	}
+
+	if l.span != nil {
 		l.logToSpan("warn", msg, fields...)
-		fields = append(l.spanFields, fields...)
 	}
 
-	l.zapLogger.Warn(msg, fields...)
+	l.zapLogger.Warn(msg, copyFields(fields)...)
 }
 
 // Warnf method output warning level log.
 func Warnf(format string, v ...interface{}) {
-	std.zapLogger.Sugar().Warnf(format, v...)
+	if !std.Enabled(WarnLevel) {
+		return
+	}
+
+	std.sugar.Warnf(format, v...)
 }
 
 func (l *zapLogger) Warnf(format string, v ...interface{}) {
-	l.zapLogger.Sugar().Warnf(format, v...)
+	if !l.Enabled(WarnLevel) {
+		return
+	}
+
+	l.sugar.Warnf(format, v...)
 }
 
 // Warnw method output warning level log.
 func Warnw(msg string, keysAndValues ...interface{}) {
-	std.zapLogger.Sugar().Warnw(msg, keysAndValues...)
+	if !std.Enabled(WarnLevel) {
+		return
+	}
+
+	std.zapLogger.Warn(msg, handleFields(std.zapLogger, keysAndValues)...)
 }
 
 func (l *zapLogger) Warnw(msg string, keysAndValues ...interface{}) {
-	l.zapLogger.Sugar().Warnw(msg, keysAndValues...)
+	if !l.Enabled(WarnLevel) {
+		return
+	}
+
+	l.zapLogger.Warn(msg, handleFields(l.zapLogger, keysAndValues)...)
 }
 
 // Error method output error level log.
 func Error(msg string, fields ...Field) {
-	std.zapLogger.Error(msg, fields...)
+	if !std.Enabled(ErrorLevel) {
+		return
+	}
+
+	std.zapLogger.Error(msg, copyFields(fields)...)
 }
 
 func (l *zapLogger) Error(msg string, fields ...Field) {
-	if l.isSpan {
+	if !l.Enabled(ErrorLevel) {
+		return
+	}
+
+	if l.span != nil {
 		l.logToSpan("error", msg, fields...)
 		tag.Error.Set(l.span, true)
-		fields = append(l.spanFields, fields...)
 	}
 
-	l.zapLogger.Error(msg, fields...)
+	l.zapLogger.Error(msg, copyFields(fields)...)
 }
 
 // Errorf method output error level log.
 func Errorf(format string, v ...interface{}) {
-	std.zapLogger.Sugar().Errorf(format, v...)
+	if !std.Enabled(ErrorLevel) {
+		return
+	}
+
+	std.sugar.Errorf(format, v...)
 }
 
 func (l *zapLogger) Errorf(format string, v ...interface{}) {
-	l.zapLogger.Sugar().Errorf(format, v...)
+	if !l.Enabled(ErrorLevel) {
+		return
+	}
+
+	l.sugar.Errorf(format, v...)
 }
 
 // Errorw method output error level log.
 func Errorw(msg string, keysAndValues ...interface{}) {
-	std.zapLogger.Sugar().Errorw(msg, keysAndValues...)
+	if !std.Enabled(ErrorLevel) {
+		return
+	}
+
+	std.zapLogger.Error(msg, handleFields(std.zapLogger, keysAndValues)...)
 }
 
 func (l *zapLogger) Errorw(msg string, keysAndValues ...interface{}) {
-	l.zapLogger.Sugar().Errorw(msg, keysAndValues...)
+	if !l.Enabled(ErrorLevel) {
+		return
+	}
+
+	l.zapLogger.Error(msg, handleFields(l.zapLogger, keysAndValues)...)
+}
+
+// DPanic method output dpanic level log, it panics in development mode.
+func DPanic(msg string, fields ...Field) {
+	std.zapLogger.DPanic(msg, fields...)
+}
+
+func (l *zapLogger) DPanic(msg string, fields ...Field) {
+	if l.span != nil && l.Enabled(DPanicLevel) {
+		l.logToSpan("dpanic", msg, fields...)
+		tag.Error.Set(l.span, true)
+	}
+
+	l.zapLogger.DPanic(msg, fields...)
+}
+
+// DPanicf method output dpanic level log, it panics in development mode.
+func DPanicf(format string, v ...interface{}) {
+	std.sugar.DPanicf(format, v...)
+}
+
+func (l *zapLogger) DPanicf(format string, v ...interface{}) {
+	l.sugar.DPanicf(format, v...)
+}
+
+// DPanicw method output dpanic level log, it panics in development mode.
+func DPanicw(msg string, keysAndValues ...interface{}) {
+	std.zapLogger.DPanic(msg, handleFields(std.zapLogger, keysAndValues)...)
+}
+
+func (l *zapLogger) DPanicw(msg string, keysAndValues ...interface{}) {
+	l.zapLogger.DPanic(msg, handleFields(l.zapLogger, keysAndValues)...)
 }
 
 // Panic method output panic level log and shutdown application.
@@ -349,10 +897,9 @@ func Panic(msg string, fields ...Field) {
 }
 
 func (l *zapLogger) Panic(msg string, fields ...Field) {
-	if l.isSpan {
+	if l.span != nil && l.Enabled(PanicLevel) {
 		l.logToSpan("panic", msg, fields...)
 		tag.Error.Set(l.span, true)
-		fields = append(l.spanFields, fields...)
 	}
 
 	l.zapLogger.Panic(msg, fields...)
@@ -360,20 +907,20 @@ func (l *zapLogger) Panic(msg string, fields ...Field) {
 
 // Panicf method output panic level log and shutdown application.
 func Panicf(format string, v ...interface{}) {
-	std.zapLogger.Sugar().Panicf(format, v...)
+	std.sugar.Panicf(format, v...)
 }
 
 func (l *zapLogger) Panicf(format string, v ...interface{}) {
-	l.zapLogger.Sugar().Panicf(format, v...)
+	l.sugar.Panicf(format, v...)
 }
 
 // Panicw method output panic level log.
 func Panicw(msg string, keysAndValues ...interface{}) {
-	std.zapLogger.Sugar().Panicw(msg, keysAndValues...)
+	std.zapLogger.Panic(msg, handleFields(std.zapLogger, keysAndValues)...)
 }
 
 func (l *zapLogger) Panicw(msg string, keysAndValues ...interface{}) {
-	l.zapLogger.Sugar().Panicw(msg, keysAndValues...)
+	l.zapLogger.Panic(msg, handleFields(l.zapLogger, keysAndValues)...)
 }
 
 // Fatal method output fatal level log.
@@ -382,10 +929,9 @@ func Fatal(msg string, fields ...Field) {
 }
 
 func (l *zapLogger) Fatal(msg string, fields ...Field) {
-	if l.isSpan {
+	if l.span != nil && l.Enabled(FatalLevel) {
 		l.logToSpan("fatal", msg, fields...)
 		tag.Error.Set(l.span, true)
-		fields = append(l.spanFields, fields...)
 	}
 
 	l.zapLogger.Fatal(msg, fields...)
@@ -393,20 +939,20 @@ func (l *zapLogger) Fatal(msg string, fields ...Field) {
 
 // Fatalf method output fatal level log.
 func Fatalf(format string, v ...interface{}) {
-	std.zapLogger.Sugar().Fatalf(format, v...)
+	std.sugar.Fatalf(format, v...)
 }
 
 func (l *zapLogger) Fatalf(format string, v ...interface{}) {
-	l.zapLogger.Sugar().Fatalf(format, v...)
+	l.sugar.Fatalf(format, v...)
 }
 
 // Fatalw method output Fatalw level log.
 func Fatalw(msg string, keysAndValues ...interface{}) {
-	std.zapLogger.Sugar().Fatalw(msg, keysAndValues...)
+	std.zapLogger.Fatal(msg, handleFields(std.zapLogger, keysAndValues)...)
 }
 
 func (l *zapLogger) Fatalw(msg string, keysAndValues ...interface{}) {
-	l.zapLogger.Sugar().Fatalw(msg, keysAndValues...)
+	l.zapLogger.Fatal(msg, handleFields(l.zapLogger, keysAndValues)...)
 }
 
 // C with context value
@@ -414,44 +960,59 @@ func C(ctx context.Context) Logger {
 	return std.C(ctx)
 }
 
+// C returns a child logger carrying the values of ctx stored under the
+// common fields keys.
 func (l *zapLogger) C(ctx context.Context) Logger {
-	// deep copy
-	lg := func(logger *zapLogger) *zapLogger {
-		return &(*logger)
-	}(l)
+	if ctx == nil {
+		return l
+	}
 
-	if len(commonFields) == 0 {
-		return lg
+	lg := l
+	if DebugElevated(ctx) {
+		lg = l.elevate()
 	}
 
-	for _, field := range commonFields {
+	fields := make([]Field, 0, len(l.commonFields))
+	for _, field := range l.commonFields {
 		if fieldVal := ctx.Value(field); fieldVal != nil {
-			lg.zapLogger = lg.zapLogger.With(zap.Any(field, fieldVal))
+			fields = append(fields, Any(field, fieldVal))
 		}
 	}
 
-	return lg
+	if len(fields) == 0 {
+		return lg
+	}
+
+	return lg.derive(lg.zapLogger.With(fields...))
 }
 
+// J with the opentracing span of context
 func J(ctx context.Context) Logger {
 	return std.J(ctx)
 }
 
+// J returns a child logger which also records its entries on the span of
+// ctx, trace_id and span_id are added as fields for jaeger spans.
 func (l *zapLogger) J(ctx context.Context) Logger {
-	ls := func(logger *zapLogger) *zapLogger {
-		logger.isSpan = true
-		return &(*logger)
-	}(l)
-
-	if span := opentracing.SpanFromContext(ctx); span != nil {
-		ls.span = span
-		if jaegerCtx, ok := span.Context().(jaeger.SpanContext); ok {
-			l.spanFields = []Field{
-				zap.String("trace_id", jaegerCtx.TraceID().String()),
-				zap.String("span_id", jaegerCtx.SpanID().String()),
-			}
-		}
+	if ctx == nil {
+		return l
+	}
+
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return l
 	}
 
-	return ls
+	z := l.zapLogger
+	if jaegerCtx, ok := span.Context().(jaeger.SpanContext); ok {
+		z = z.With(
+			zap.String("trace_id", jaegerCtx.TraceID().String()),
+			zap.String("span_id", jaegerCtx.SpanID().String()),
+		)
+	}
+
+	lg := l.derive(z)
+	lg.span = span
+
+	return lg
 }
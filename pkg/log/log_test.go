@@ -0,0 +1,211 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"go.uber.org/zap"
+)
+
+// newTestOptions returns options writing JSON entries of every level to a
+// file of the test directory
+func newTestOptions(t testing.TB) *Options {
+	t.Helper()
+
+	dir := t.TempDir()
+	opts := NewOptions()
+	opts.Format = jsonFormat
+	opts.Level = "trace"
+	opts.OutputPaths = []string{filepath.Join(dir, "test.log")}
+	opts.ErrorOutputPaths = []string{filepath.Join(dir, "error.log")}
+
+	return opts
+}
+
+// newTestLogger creates a logger of opts, newTestOptions by default, and
+// returns a function reading the entries it wrote
+func newTestLogger(t testing.TB, opts *Options) (*zapLogger, func() []map[string]interface{}) {
+	t.Helper()
+
+	if opts == nil {
+		opts = newTestOptions(t)
+	}
+
+	l := New(opts)
+	t.Cleanup(func() { _ = l.Close() })
+
+	return l, func() []map[string]interface{} {
+		t.Helper()
+
+		l.Flush()
+
+		return readEntries(t, opts.OutputPaths[0])
+	}
+}
+
+// readEntries reads the JSON entries of a log file
+func readEntries(t testing.TB, path string) []map[string]interface{} {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid entry %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return entries
+}
+
+func TestDeriveConcurrently(t *testing.T) {
+	l, entries := newTestLogger(t, nil)
+
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+
+	const goroutines, iterations = 16, 50
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				span := tracer.StartSpan("op")
+				ctx := opentracing.ContextWithSpan(context.WithValue(context.Background(), keyRequestID, g), span)
+
+				l.J(ctx).C(ctx).WithValues("goroutine", g).Info("derived")
+				l.C(ctx).WithName("child").Debug("named")
+				span.Finish()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	var derived int
+	for _, entry := range entries() {
+		if entry["message"] != "derived" {
+			continue
+		}
+		derived++
+
+		if entry["requestID"] != entry["goroutine"] {
+			t.Fatalf("requestID %v logged by goroutine %v", entry["requestID"], entry["goroutine"])
+		}
+		if entry["trace_id"] == nil || entry["span_id"] == nil {
+			t.Fatalf("entry without span: %v", entry)
+		}
+	}
+
+	// the sampler keeps the first 100 entries of a message per second
+	if derived < 100 {
+		t.Fatalf("%d derived entries, want at least 100", derived)
+	}
+}
+
+func TestDeriveIsolation(t *testing.T) {
+	l, entries := newTestLogger(t, nil)
+
+	ctx := context.WithValue(context.Background(), keyRequestID, "r1")
+	parent := l.WithValues("parent", true)
+	parent.C(ctx).WithName("child").Info("child")
+	parent.Info("parent")
+
+	got := entries()
+	if len(got) != 2 {
+		t.Fatalf("%d entries, want 2", len(got))
+	}
+	if got[0]["requestID"] != "r1" || got[0]["logger"] != "child" {
+		t.Fatalf("child entry %v", got[0])
+	}
+	if _, ok := got[1]["requestID"]; ok || got[1]["logger"] != nil || got[1]["parent"] != true {
+		t.Fatalf("parent entry modified by its child: %v", got[1])
+	}
+}
+
+// newDisabledLogger returns a logger at error level, with a span if span.
+// The benchmarks call it rather than Logger, whose calls allocate their
+// variadic arguments.
+func newDisabledLogger(t testing.TB, span bool) *zapLogger {
+	t.Helper()
+
+	opts := newTestOptions(t)
+	opts.Level = "error"
+	l, _ := newTestLogger(t, opts)
+	if !span {
+		return l
+	}
+
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	t.Cleanup(func() { closer.Close() })
+
+	return l.J(opentracing.ContextWithSpan(context.Background(), tracer.StartSpan("op"))).(*zapLogger)
+}
+
+// disabledCalls log at the disabled debug level
+var disabledCalls = []struct {
+	name string
+	span bool
+	log  func(l *zapLogger)
+}{
+	{name: "Debugf", log: func(l *zapLogger) { l.Debugf("request %s took %d ms", "GET /", 12) }},
+	{name: "Debugw", log: func(l *zapLogger) { l.Debugw("request", "path", "GET /", "elapsed", 12) }},
+	{name: "Debug", log: func(l *zapLogger) { l.Debug("request", zap.String("path", "GET /"), zap.Int("elapsed", 12)) }},
+	{name: "DebugSpan", span: true, log: func(l *zapLogger) {
+		l.Debug("request", zap.String("path", "GET /"), zap.Int("elapsed", 12))
+	}},
+	{name: "PackageDebug", log: func(*zapLogger) { Debug("request", zap.String("path", "GET /")) }},
+}
+
+func benchmarkDisabled(b *testing.B, name string) {
+	for _, call := range disabledCalls {
+		if call.name != name {
+			continue
+		}
+
+		l := newDisabledLogger(b, call.span)
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			call.log(l)
+		}
+	}
+}
+
+func BenchmarkDisabledDebugf(b *testing.B)    { benchmarkDisabled(b, "Debugf") }
+func BenchmarkDisabledDebugw(b *testing.B)    { benchmarkDisabled(b, "Debugw") }
+func BenchmarkDisabledDebug(b *testing.B)     { benchmarkDisabled(b, "Debug") }
+func BenchmarkDisabledDebugSpan(b *testing.B) { benchmarkDisabled(b, "DebugSpan") }
+
+func TestDisabledAllocs(t *testing.T) {
+	for _, call := range disabledCalls {
+		l := newDisabledLogger(t, call.span)
+		if allocs := testing.AllocsPerRun(100, func() { call.log(l) }); allocs != 0 {
+			t.Errorf("disabled %s: %v allocations, want 0", call.name, allocs)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+// logcheck checks the calls of the log package, run it with go vet:
+//
+//	go vet -vettool=$(which logcheck) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"git.enn-edge.com/device_manage/public/log.git/logcheck"
+)
+
+func main() {
+	unitchecker.Main(logcheck.Analyzer)
+}
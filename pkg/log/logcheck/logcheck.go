@@ -0,0 +1,263 @@
+// Package logcheck defines an Analyzer that reports misuses of the
+// key-value and formatted logging API of the log package.
+package logcheck
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const doc = `check calls of the key-value and formatted logging API
+
+The logcheck analyzer reports calls of the *w methods and WithValues of the
+log package and of zap's SugaredLogger whose key-value pairs are odd,
+have non-string keys or contain strongly-typed zap fields, and calls of
+the *f methods whose format string doesn't match their arguments.
+With -key-pattern, keys are also checked against a naming convention.`
+
+const (
+	logPkgPath = "git.enn-edge.com/device_manage/public/log.git"
+	zapPkgPath = "go.uber.org/zap"
+)
+
+// Analyzer reports misuses of the logging API
+var Analyzer = &analysis.Analyzer{
+	Name:     "logcheck",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// keyPattern is the naming convention of the keys, empty means any key
+var keyPattern string
+
+func init() {
+	Analyzer.Flags.StringVar(&keyPattern, "key-pattern", "",
+		"regular expression keys must match, or one of the conventions camel, snake and kebab")
+}
+
+// keyConventions are the predefined key naming conventions
+var keyConventions = map[string]string{
+	"camel": `^[a-z][a-zA-Z0-9]*$`,
+	"snake": `^[a-z][a-z0-9]*(_[a-z0-9]+)*$`,
+	"kebab": `^[a-z][a-z0-9]*(-[a-z0-9]+)*$`,
+}
+
+// fieldTypes are the names of zap's Field, the alias included
+var fieldTypes = map[string]bool{
+	"go.uber.org/zap/zapcore.Field": true,
+	"go.uber.org/zap.Field":         true,
+}
+
+// keyValueFuncs take key-value pairs as variadic parameter
+var keyValueFuncs = map[string]bool{
+	"Tracew": true, "Debugw": true, "Infow": true, "Noticew": true, "Warnw": true,
+	"Errorw": true, "DPanicw": true, "Panicw": true, "Fatalw": true,
+	"WithValues": true, "With": true,
+}
+
+// formatFuncs take a format string and its arguments
+var formatFuncs = map[string]bool{
+	"Tracef": true, "Debugf": true, "Infof": true, "Noticef": true, "Warnf": true,
+	"Errorf": true, "DPanicf": true, "Panicf": true, "Fatalf": true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	var keyRe *regexp.Regexp
+	if keyPattern != "" {
+		pattern, ok := keyConventions[keyPattern]
+		if !ok {
+			pattern = keyPattern
+		}
+
+		var err error
+		if keyRe, err = regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+
+		fn := callee(pass, call)
+		if fn == nil || !isLogAPI(fn) {
+			return
+		}
+
+		// args... can't be checked statically
+		if call.Ellipsis.IsValid() {
+			return
+		}
+
+		sig := fn.Type().(*types.Signature)
+		if !sig.Variadic() || len(call.Args) < sig.Params().Len()-1 {
+			return
+		}
+		fixed := sig.Params().Len() - 1
+
+		switch {
+		case keyValueFuncs[fn.Name()]:
+			// zap.Logger.With takes fields, not key-value pairs
+			if fn.Name() == "With" && !isSugared(fn) {
+				return
+			}
+			checkKeyValues(pass, call, call.Args[fixed:], keyRe)
+		case formatFuncs[fn.Name()]:
+			checkFormat(pass, call, call.Args[fixed-1], call.Args[fixed:])
+		}
+	})
+
+	return nil, nil
+}
+
+// callee returns the function or method called, nil for other calls
+func callee(pass *analysis.Pass, call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil
+	}
+
+	fn, _ := pass.TypesInfo.Uses[id].(*types.Func)
+
+	return fn
+}
+
+// isLogAPI reports whether fn is declared by the log package or by zap
+func isLogAPI(fn *types.Func) bool {
+	if fn.Pkg() == nil {
+		return false
+	}
+
+	path := fn.Pkg().Path()
+
+	return path == logPkgPath || path == zapPkgPath
+}
+
+func isSugared(fn *types.Func) bool {
+	recv := fn.Type().(*types.Signature).Recv()
+
+	return recv != nil && strings.HasSuffix(recv.Type().String(), "SugaredLogger")
+}
+
+func checkKeyValues(pass *analysis.Pass, call *ast.CallExpr, args []ast.Expr, keyRe *regexp.Regexp) {
+	for i := 0; i < len(args); i += 2 {
+		key := args[i]
+		keyType := pass.TypesInfo.TypeOf(key)
+
+		if keyType != nil && fieldTypes[keyType.String()] {
+			pass.Reportf(key.Pos(), "strongly-typed zap Field passed as key-value pair")
+
+			return
+		}
+
+		if i == len(args)-1 {
+			pass.Reportf(key.Pos(), "odd number of arguments passed as key-value pairs, the last key has no value")
+
+			return
+		}
+
+		if keyType == nil {
+			continue
+		}
+
+		basic, ok := keyType.Underlying().(*types.Basic)
+		if !ok || basic.Info()&types.IsString == 0 {
+			// interface values may hold string keys
+			if _, isInterface := keyType.Underlying().(*types.Interface); !isInterface {
+				pass.Reportf(key.Pos(), "non-string key of type %s passed as key-value pair", keyType)
+
+				return
+			}
+
+			continue
+		}
+
+		if keyRe == nil {
+			continue
+		}
+
+		if tv := pass.TypesInfo.Types[key]; tv.Value != nil && tv.Value.Kind() == constant.String {
+			if name := constant.StringVal(tv.Value); !keyRe.MatchString(name) {
+				pass.Reportf(key.Pos(), "key %q doesn't match the naming convention %s", name, keyPattern)
+			}
+		}
+	}
+}
+
+func checkFormat(pass *analysis.Pass, call *ast.CallExpr, format ast.Expr, args []ast.Expr) {
+	tv := pass.TypesInfo.Types[format]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return
+	}
+
+	want, ok := countArgs(pass, format, constant.StringVal(tv.Value))
+	if !ok {
+		return
+	}
+
+	if want != len(args) {
+		pass.Reportf(call.Pos(), "format %q reads %d arg(s), but call has %d", constant.StringVal(tv.Value), want, len(args))
+	}
+}
+
+// countArgs returns the number of arguments a format string reads. It is
+// not ok if the arguments are explicitly indexed or a verb is invalid.
+func countArgs(pass *analysis.Pass, format ast.Expr, s string) (int, bool) {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		i++
+
+		// flags
+		for i < len(s) && strings.IndexByte("+-# 0", s[i]) >= 0 {
+			i++
+		}
+
+		// width and precision
+		for i < len(s) && (s[i] == '*' || s[i] == '.' || s[i] >= '0' && s[i] <= '9' || s[i] == '[') {
+			switch s[i] {
+			case '*':
+				n++
+			case '[':
+				return 0, false
+			}
+			i++
+		}
+
+		if i >= len(s) {
+			pass.Reportf(format.Pos(), "format %q ends with an incomplete verb", s)
+
+			return 0, false
+		}
+
+		if s[i] == '%' {
+			continue
+		}
+
+		verb, _ := utf8.DecodeRuneInString(s[i:])
+		if !strings.ContainsRune("bcdeEfFgGopqstTUvxX", verb) {
+			pass.Reportf(format.Pos(), "format %q has unknown verb %%%c", s, verb)
+
+			return 0, false
+		}
+		n++
+	}
+
+	return n, true
+}
@@ -0,0 +1,75 @@
+package logcheck_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.enn-edge.com/device_manage/public/log.git/logcheck"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+// The test packages are in the testdata module, which requires the log
+// package of this repository and zap, their expected diagnostics are
+// regular expressions in // want comments.
+
+func testdata(t *testing.T) string {
+	dir, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, testdata(t), logcheck.Analyzer, "./a")
+}
+
+func TestAnalyzerKeyPattern(t *testing.T) {
+	if err := logcheck.Analyzer.Flags.Set("key-pattern", "camel"); err != nil {
+		t.Fatal(err)
+	}
+	defer logcheck.Analyzer.Flags.Set("key-pattern", "")
+
+	analysistest.Run(t, testdata(t), logcheck.Analyzer, "./keys")
+}
+
+// TestVet checks that go vet fails on the diagnostics of the vet tool, so
+// that it can gate a build
+func TestVet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the vet tool")
+	}
+
+	tool := filepath.Join(t.TempDir(), "logcheck")
+	build := exec.Command("go", "build", "-o", tool, "./cmd/logcheck")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building the vet tool: %v\n%s", err, out)
+	}
+
+	vet := func(pkg string) (string, error) {
+		cmd := exec.Command("go", "vet", "-vettool="+tool, pkg)
+		cmd.Dir = testdata(t)
+		cmd.Env = append(os.Environ(), "GOPROXY=off", "GOWORK=off")
+		out, err := cmd.CombinedOutput()
+
+		return string(out), err
+	}
+
+	if out, err := vet("./clean"); err != nil {
+		t.Fatalf("vet of a package without misuse failed: %v\n%s", err, out)
+	}
+
+	out, err := vet("./a")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() == 0 {
+		t.Fatalf("vet of a package with misuses returned %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "odd number of arguments") {
+		t.Fatalf("vet output has no diagnostic:\n%s", out)
+	}
+}
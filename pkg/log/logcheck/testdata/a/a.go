@@ -0,0 +1,42 @@
+package a
+
+import (
+	log "git.enn-edge.com/device_manage/public/log.git"
+	"go.uber.org/zap"
+)
+
+type key string
+
+func keyValues(l log.Logger, s *zap.SugaredLogger, z *zap.Logger, k interface{}, args []interface{}) {
+	log.Infow("ok", "user", "bob", "id", 1)
+	log.Infow("odd", "user", "bob", "id")         // want `odd number of arguments passed as key-value pairs, the last key has no value`
+	log.Infow("field", zap.String("user", "bob")) // want `strongly-typed zap Field passed as key-value pair`
+	log.Infow("non-string", 1, "bob")             // want `non-string key of type int passed as key-value pair`
+	log.Infow("named string", key("user"), "bob")
+	log.Infow("interface", k, "bob")
+	log.Infow("spread", args...)
+
+	l.Infow("odd", "user")                    // want `odd number of arguments`
+	l.WithValues("user", "bob", "id")         // want `odd number of arguments`
+	log.WithValues(zap.String("user", "bob")) // want `strongly-typed zap Field`
+
+	s.Infow("odd", "user") // want `odd number of arguments`
+	s.With(1, "bob")       // want `non-string key of type int`
+
+	// zap.Logger.With takes fields
+	z.With(zap.String("user", "bob"))
+}
+
+func formats(l log.Logger, s *zap.SugaredLogger, format string) {
+	log.Debugf("%s took %d ms", "GET", 12)
+	log.Debugf("%s took %d ms", "GET") // want `format "%s took %d ms" reads 2 arg\(s\), but call has 1`
+	log.Debugf("100%% done", 1)        // want `format "100%% done" reads 0 arg\(s\), but call has 1`
+	log.Debugf("%*d", 4, 12)
+	log.Debugf("%[1]s %[1]s", "indexed")
+	log.Debugf("%y", 1)           // want `format "%y" has unknown verb %y`
+	log.Debugf("incomplete %", 1) // want `format "incomplete %" ends with an incomplete verb`
+	log.Debugf(format, 1)
+
+	l.Errorf("%v", 1, 2) // want `reads 1 arg\(s\), but call has 2`
+	s.Infof("%d %d", 1)  // want `reads 2 arg\(s\), but call has 1`
+}
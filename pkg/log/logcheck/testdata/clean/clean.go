@@ -0,0 +1,8 @@
+package clean
+
+import log "git.enn-edge.com/device_manage/public/log.git"
+
+func clean() {
+	log.Infow("ok", "user", "bob", "id", 1)
+	log.Debugf("%s took %d ms", "GET", 12)
+}
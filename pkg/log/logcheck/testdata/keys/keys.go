@@ -0,0 +1,10 @@
+package keys
+
+import log "git.enn-edge.com/device_manage/public/log.git"
+
+func keys(name string) {
+	log.Infow("camel", "userID", 1, "requestPath", "/")
+	log.Infow("snake", "user_id", 1) // want `key "user_id" doesn't match the naming convention camel`
+	log.Infow("kebab", "user-id", 1) // want `key "user-id" doesn't match the naming convention camel`
+	log.Infow("variable", name, 1)
+}
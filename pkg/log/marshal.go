@@ -0,0 +1,643 @@
+package log
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// tagName is the struct tag read by the object marshaler, its value is
+// `log:"name,omitempty,redact"`, a "-" name skips the field
+const tagName = "log"
+
+// Redacted replaces the values of the fields tagged with redact
+const Redacted = "[REDACTED]"
+
+var (
+	objectMarshalerType = reflect.TypeOf((*zapcore.ObjectMarshaler)(nil)).Elem()
+	arrayMarshalerType  = reflect.TypeOf((*zapcore.ArrayMarshaler)(nil)).Elem()
+	errorType           = reflect.TypeOf((*error)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType        = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// maxMarshalDepth bounds the nesting of the values marshaled from their
+// tags, the values nested deeper, as those with a pointer cycle, return
+// errMarshalDepth rather than overflowing the stack
+const maxMarshalDepth = 100
+
+var errMarshalDepth = fmt.Errorf("log: value nested deeper than %d, it may have a pointer cycle", maxMarshalDepth)
+
+// Any takes a key and an arbitrary value and chooses the best way to
+// represent them as a field. Structs with log tags, in their own fields or
+// in nested structs, pointers, slices, arrays and maps, and the slices,
+// arrays and maps of such structs are marshaled as described by the tags,
+// anything else falls back on zap.Any. The interfaces nested in them are
+// marshaled as their dynamic values.
+func Any(key string, value interface{}) Field {
+	if value != nil {
+		if _, ok := value.(zapcore.ObjectMarshaler); !ok {
+			if f, ok := taggedField(key, reflect.ValueOf(value)); ok {
+				return f
+			}
+		}
+	}
+
+	return zap.Any(key, value)
+}
+
+// taggedField returns the field of v if its type has log tags, nil values
+// are left to zap.Any
+func taggedField(key string, v reflect.Value) (Field, bool) {
+	t := v.Type()
+	if !hasTags(t) {
+		return Field{}, false
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return Field{}, false
+		}
+		if t.Elem().Kind() == reflect.Struct {
+			return zap.Object(key, objectMarshaler{m: encoderOf(t), v: v}), true
+		}
+
+		return taggedField(key, v.Elem())
+	case reflect.Struct:
+		return zap.Object(key, objectMarshaler{m: encoderOf(t), v: v}), true
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return Field{}, false
+		}
+
+		return zap.Array(key, valuesMarshaler{append: appendFuncOf(t.Elem()), v: v}), true
+	case reflect.Map:
+		if v.IsNil() {
+			return Field{}, false
+		}
+
+		return zap.Object(key, mapMarshaler{encode: encodeFuncOf(t.Elem()), v: v}), true
+	}
+
+	return Field{}, false
+}
+
+var taggedTypes sync.Map // map[reflect.Type]bool
+
+// hasTags reports whether a struct reachable from t, through the struct
+// fields, pointers and the elements of slices, arrays and maps, has a log
+// tag. The nested values encoded by their own marshalers aren't inspected.
+func hasTags(t reflect.Type) bool {
+	if tagged, ok := taggedTypes.Load(t); ok {
+		return tagged.(bool)
+	}
+
+	tagged := typeHasTags(t, map[reflect.Type]bool{})
+	taggedTypes.Store(t, tagged)
+
+	return tagged
+}
+
+func typeHasTags(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		// the methods of the pointed values are those of the pointers
+		return typeHasTags(t.Elem(), seen)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return nestedHasTags(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if _, ok := f.Tag.Lookup(tagName); ok {
+				return true
+			}
+
+			if (f.IsExported() || f.Anonymous) && nestedHasTags(f.Type, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// nestedHasTags is typeHasTags for the nested types, which encodeFuncOf
+// encodes with their marshalers if they have one
+func nestedHasTags(t reflect.Type, seen map[reflect.Type]bool) bool {
+	switch {
+	case t == timeType, t == durationType,
+		t.Implements(objectMarshalerType), t.Implements(arrayMarshalerType), t.Implements(errorType),
+		t.Implements(textMarshalerType), t.Implements(stringerType):
+		return false
+	}
+
+	return typeHasTags(t, seen)
+}
+
+// Object constructs a field of a struct, or a pointer to a struct, whose
+// exported fields are encoded as described by their log tags. Other values
+// are handled by zap.Any.
+func Object(key string, value interface{}) Field {
+	m := ObjectMarshaler(value)
+	if m == nil {
+		return zap.Any(key, value)
+	}
+
+	return zap.Object(key, m)
+}
+
+// ObjectMarshaler returns the zapcore.ObjectMarshaler of the value as
+// described by its log tags, nil if the value isn't a struct or a pointer
+// to a struct. The encoding plan of a type is built once and cached.
+func ObjectMarshaler(value interface{}) zapcore.ObjectMarshaler {
+	if m, ok := value.(zapcore.ObjectMarshaler); ok {
+		return m
+	}
+
+	if value == nil || structType(reflect.TypeOf(value)) == nil {
+		return nil
+	}
+
+	return objectMarshaler{m: encoderOf(reflect.TypeOf(value)), v: reflect.ValueOf(value)}
+}
+
+// structType returns the struct type of t or of the type t points to
+func structType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return t
+}
+
+// objectMarshaler encodes a struct, depth is the number of marshalers it
+// is nested in
+type objectMarshaler struct {
+	m     *structEncoder
+	v     reflect.Value
+	depth int
+}
+
+func (o objectMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if o.depth > maxMarshalDepth {
+		return errMarshalDepth
+	}
+
+	v := o.v
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	return o.m.encode(enc, v, o.depth)
+}
+
+// structEncoder is the cached encoding plan of a struct type
+type structEncoder struct {
+	fields []fieldEncoder
+}
+
+type fieldEncoder struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	redact    bool
+	encode    encodeFunc
+}
+
+// encodeFunc encodes v in an object nested depth marshalers deep
+type encodeFunc func(enc zapcore.ObjectEncoder, key string, v reflect.Value, depth int) error
+
+var structEncoders sync.Map // map[reflect.Type]*structEncoder
+
+func encoderOf(t reflect.Type) *structEncoder {
+	t = structType(t)
+	if m, ok := structEncoders.Load(t); ok {
+		return m.(*structEncoder)
+	}
+
+	m := &structEncoder{}
+	m.build(t, nil)
+	actual, _ := structEncoders.LoadOrStore(t, m)
+
+	return actual.(*structEncoder)
+}
+
+func (m *structEncoder) build(t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get(tagName)
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldIndex := append(append([]int{}, index...), i)
+
+		// embedded structs without a name are inlined
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			m.build(f.Type, fieldIndex)
+
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		m.fields = append(m.fields, fieldEncoder{
+			name:      name,
+			index:     fieldIndex,
+			omitEmpty: hasOption(opts, "omitempty"),
+			redact:    hasOption(opts, "redact"),
+			encode:    encodeFuncOf(f.Type),
+		})
+	}
+}
+
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *structEncoder) encode(enc zapcore.ObjectEncoder, v reflect.Value, depth int) error {
+	for _, f := range m.fields {
+		fv := v.FieldByIndex(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		if f.redact {
+			enc.AddString(f.name, Redacted)
+
+			continue
+		}
+
+		if err := f.encode(enc, f.name, fv, depth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeFuncOf returns the function encoding the values of type t, the
+// type is inspected once so that encoding doesn't go through zap.Any
+func encodeFuncOf(t reflect.Type) encodeFunc {
+	switch {
+	case t == timeType:
+		return interfaceEncoder(func(enc zapcore.ObjectEncoder, key string, v interface{}) error {
+			enc.AddTime(key, v.(time.Time))
+
+			return nil
+		})
+	case t == durationType:
+		return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, _ int) error {
+			enc.AddDuration(key, time.Duration(v.Int()))
+
+			return nil
+		}
+	case t.Implements(objectMarshalerType):
+		return interfaceEncoder(func(enc zapcore.ObjectEncoder, key string, v interface{}) error {
+			return enc.AddObject(key, v.(zapcore.ObjectMarshaler))
+		})
+	case t.Implements(arrayMarshalerType):
+		return interfaceEncoder(func(enc zapcore.ObjectEncoder, key string, v interface{}) error {
+			return enc.AddArray(key, v.(zapcore.ArrayMarshaler))
+		})
+	case t.Implements(errorType):
+		return interfaceEncoder(func(enc zapcore.ObjectEncoder, key string, v interface{}) error {
+			enc.AddString(key, v.(error).Error())
+
+			return nil
+		})
+	case t.Implements(textMarshalerType):
+		return interfaceEncoder(func(enc zapcore.ObjectEncoder, key string, v interface{}) error {
+			text, err := v.(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return err
+			}
+			enc.AddByteString(key, text)
+
+			return nil
+		})
+	case t.Implements(stringerType):
+		return interfaceEncoder(func(enc zapcore.ObjectEncoder, key string, v interface{}) error {
+			enc.AddString(key, v.(fmt.Stringer).String())
+
+			return nil
+		})
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, _ int) error {
+			enc.AddBool(key, v.Bool())
+
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, _ int) error {
+			enc.AddInt64(key, v.Int())
+
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, _ int) error {
+			enc.AddUint64(key, v.Uint())
+
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, _ int) error {
+			enc.AddFloat64(key, v.Float())
+
+			return nil
+		}
+	case reflect.String:
+		return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, _ int) error {
+			enc.AddString(key, v.String())
+
+			return nil
+		}
+	case reflect.Struct:
+		// nested encoders are looked up lazily so that recursive types
+		// don't recurse while building
+		return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, depth int) error {
+			return enc.AddObject(key, objectMarshaler{m: encoderOf(t), v: v, depth: depth + 1})
+		}
+	case reflect.Ptr:
+		elem := encodeFuncOf(t.Elem())
+
+		return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, depth int) error {
+			if v.IsNil() {
+				return enc.AddReflected(key, nil)
+			}
+
+			return elem(enc, key, v.Elem(), depth)
+		}
+	case reflect.Interface:
+		// the dynamic values with log tags are encoded with their plans
+		return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, depth int) error {
+			if !v.CanInterface() {
+				return nil
+			}
+			if v.IsNil() {
+				return enc.AddReflected(key, nil)
+			}
+
+			if e := v.Elem(); hasTags(e.Type()) {
+				return cachedEncodeFunc(e.Type())(enc, key, e, depth)
+			}
+
+			return enc.AddReflected(key, v.Interface())
+		}
+	case reflect.Slice, reflect.Array:
+		if plannedElem(t.Elem()) {
+			elem := appendFuncOf(t.Elem())
+
+			return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, depth int) error {
+				return enc.AddArray(key, valuesMarshaler{append: elem, v: v, depth: depth + 1})
+			}
+		}
+	case reflect.Map:
+		if hasTags(t.Elem()) || t.Elem().Kind() == reflect.Interface {
+			elem := encodeFuncOf(t.Elem())
+
+			return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, depth int) error {
+				if v.IsNil() {
+					return enc.AddReflected(key, nil)
+				}
+
+				return enc.AddObject(key, mapMarshaler{encode: elem, v: v, depth: depth + 1})
+			}
+		}
+	}
+
+	return interfaceEncoder(func(enc zapcore.ObjectEncoder, key string, v interface{}) error {
+		return enc.AddReflected(key, v)
+	})
+}
+
+var (
+	encodeFuncs sync.Map // map[reflect.Type]encodeFunc
+	appendFuncs sync.Map // map[reflect.Type]appendFunc
+)
+
+// cachedEncodeFunc is encodeFuncOf for the dynamic types of interfaces,
+// built once per type
+func cachedEncodeFunc(t reflect.Type) encodeFunc {
+	if fn, ok := encodeFuncs.Load(t); ok {
+		return fn.(encodeFunc)
+	}
+
+	fn, _ := encodeFuncs.LoadOrStore(t, encodeFuncOf(t))
+
+	return fn.(encodeFunc)
+}
+
+// cachedAppendFunc is appendFuncOf for the dynamic types of interfaces,
+// built once per type
+func cachedAppendFunc(t reflect.Type) appendFunc {
+	if fn, ok := appendFuncs.Load(t); ok {
+		return fn.(appendFunc)
+	}
+
+	fn, _ := appendFuncs.LoadOrStore(t, appendFuncOf(t))
+
+	return fn.(appendFunc)
+}
+
+// interfaceEncoder adapts the encoders needing the interface of the value,
+// nil pointers and interfaces are encoded as null
+func interfaceEncoder(fn func(enc zapcore.ObjectEncoder, key string, v interface{}) error) encodeFunc {
+	return func(enc zapcore.ObjectEncoder, key string, v reflect.Value, _ int) error {
+		// fields promoted from unexported embedded structs
+		if !v.CanInterface() {
+			return nil
+		}
+
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			if v.IsNil() {
+				return enc.AddReflected(key, nil)
+			}
+		}
+
+		return fn(enc, key, v.Interface())
+	}
+}
+
+// plannedElem reports whether the elements of type t of the slices and
+// arrays are encoded with the plans, which are the structs, the values
+// with log tags and the interfaces, which may hold them
+func plannedElem(t reflect.Type) bool {
+	if t.Implements(objectMarshalerType) {
+		return false
+	}
+
+	return structType(t) != nil || hasTags(t) || t.Kind() == reflect.Interface
+}
+
+// appendFunc appends v to an array nested depth marshalers deep
+type appendFunc func(enc zapcore.ArrayEncoder, v reflect.Value, depth int) error
+
+// appendFuncOf returns the function appending the elements of type t of
+// the slices and arrays
+func appendFuncOf(t reflect.Type) appendFunc {
+	switch {
+	case t.Implements(objectMarshalerType):
+	case structType(t) != nil:
+		m := encoderOf(t)
+
+		return func(enc zapcore.ArrayEncoder, v reflect.Value, depth int) error {
+			return enc.AppendObject(objectMarshaler{m: m, v: v, depth: depth + 1})
+		}
+	case t.Kind() == reflect.Interface:
+		return func(enc zapcore.ArrayEncoder, v reflect.Value, depth int) error {
+			if !v.CanInterface() {
+				return nil
+			}
+			if v.IsNil() {
+				return enc.AppendReflected(nil)
+			}
+
+			if e := v.Elem(); hasTags(e.Type()) {
+				return cachedAppendFunc(e.Type())(enc, e, depth)
+			}
+
+			return enc.AppendReflected(v.Interface())
+		}
+	case !hasTags(t):
+	case t.Kind() == reflect.Ptr:
+		elem := appendFuncOf(t.Elem())
+
+		return func(enc zapcore.ArrayEncoder, v reflect.Value, depth int) error {
+			if v.IsNil() {
+				return enc.AppendReflected(nil)
+			}
+
+			return elem(enc, v.Elem(), depth)
+		}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		elem := appendFuncOf(t.Elem())
+
+		return func(enc zapcore.ArrayEncoder, v reflect.Value, depth int) error {
+			if t.Kind() == reflect.Slice && v.IsNil() {
+				return enc.AppendReflected(nil)
+			}
+
+			return enc.AppendArray(valuesMarshaler{append: elem, v: v, depth: depth + 1})
+		}
+	case t.Kind() == reflect.Map:
+		elem := encodeFuncOf(t.Elem())
+
+		return func(enc zapcore.ArrayEncoder, v reflect.Value, depth int) error {
+			if v.IsNil() {
+				return enc.AppendReflected(nil)
+			}
+
+			return enc.AppendObject(mapMarshaler{encode: elem, v: v, depth: depth + 1})
+		}
+	}
+
+	return func(enc zapcore.ArrayEncoder, v reflect.Value, _ int) error {
+		if !v.CanInterface() {
+			return nil
+		}
+
+		return enc.AppendReflected(v.Interface())
+	}
+}
+
+// valuesMarshaler encodes the slices and arrays of structs and of values
+// with log tags
+type valuesMarshaler struct {
+	append appendFunc
+	v      reflect.Value
+	depth  int
+}
+
+func (o valuesMarshaler) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	if o.depth > maxMarshalDepth {
+		return errMarshalDepth
+	}
+
+	for i := 0; i < o.v.Len(); i++ {
+		if err := o.append(enc, o.v.Index(i), o.depth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mapMarshaler encodes the maps of values with log tags as objects, sorted
+// by key
+type mapMarshaler struct {
+	encode encodeFunc
+	v      reflect.Value
+	depth  int
+}
+
+func (o mapMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if o.depth > maxMarshalDepth {
+		return errMarshalDepth
+	}
+
+	keys := o.v.MapKeys()
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		if k.Kind() == reflect.String {
+			names[i] = k.String()
+		} else {
+			names[i] = fmt.Sprint(k)
+		}
+	}
+
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return names[order[i]] < names[order[j]] })
+
+	for _, i := range order {
+		if err := o.encode(enc, names[i], o.v.MapIndex(keys[i]), o.depth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package log
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type testUser struct {
+	Name     string `log:"name"`
+	Password string `log:"password,redact"`
+}
+
+// testRequest has no log tags, only its nested users have
+type testRequest struct {
+	ID     string
+	User   testUser
+	Admin  *testUser
+	Users  []*testUser
+	ByName map[string]testUser
+}
+
+// untagged has no log tag at any depth
+type untagged struct {
+	Name  string
+	Inner struct{ Value int }
+}
+
+// encodeField returns the value of the field f as encoded in a map
+func encodeField(t *testing.T, f Field) interface{} {
+	t.Helper()
+
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+
+	return enc.Fields[f.Key]
+}
+
+func TestAnyRedactsNested(t *testing.T) {
+	bob := testUser{Name: "bob", Password: "secret"}
+	redacted := map[string]interface{}{"name": "bob", "password": Redacted}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{name: "struct", value: bob, want: redacted},
+		{name: "pointer", value: &bob, want: redacted},
+		{
+			name:  "nested struct",
+			value: testRequest{ID: "r1", User: bob},
+			want: map[string]interface{}{
+				"ID":     "r1",
+				"User":   redacted,
+				"Admin":  nil,
+				"Users":  []interface{}{},
+				"ByName": nil,
+			},
+		},
+		{
+			name:  "nested pointer, slice and map",
+			value: &testRequest{Admin: &bob, Users: []*testUser{&bob}, ByName: map[string]testUser{"bob": bob}},
+			want: map[string]interface{}{
+				"ID":     "",
+				"User":   map[string]interface{}{"name": "", "password": Redacted},
+				"Admin":  redacted,
+				"Users":  []interface{}{redacted},
+				"ByName": map[string]interface{}{"bob": redacted},
+			},
+		},
+		{name: "slice of pointers", value: []*testUser{&bob, &bob}, want: []interface{}{redacted, redacted}},
+		{name: "array", value: [1]testUser{bob}, want: []interface{}{redacted}},
+		{name: "map", value: map[string]testUser{"b": bob, "a": bob}, want: map[string]interface{}{"a": redacted, "b": redacted}},
+		{name: "map of slices", value: map[int][]testUser{1: {bob}}, want: map[string]interface{}{"1": []interface{}{redacted}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeField(t, Any("value", tt.value)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("encoded %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnyUntagged(t *testing.T) {
+	for _, value := range []interface{}{
+		untagged{Name: "bob"},
+		[]untagged{{Name: "bob"}},
+		map[string]*untagged{"bob": {Name: "bob"}},
+		[]*testUser(nil),
+		map[string]testUser(nil),
+		(*testUser)(nil),
+	} {
+		if f := Any("value", value); f.Type != zapcore.ReflectType {
+			t.Errorf("%T encoded as %v, want zap.Any", value, f.Type)
+		}
+	}
+}
+
+func TestAnyRedactsEntries(t *testing.T) {
+	opts := newTestOptions(t)
+	l, entries := newTestLogger(t, opts)
+
+	bob := testUser{Name: "bob", Password: "secret"}
+	l.Info("request",
+		Any("request", testRequest{User: bob, Users: []*testUser{&bob}}),
+		Any("users", []*testUser{&bob}),
+		Any("byName", map[string]testUser{"bob": bob}))
+
+	if got := entries(); len(got) != 1 {
+		t.Fatalf("%d entries, want 1", len(got))
+	}
+
+	data, err := os.ReadFile(opts.OutputPaths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("password not redacted: %s", data)
+	}
+}
+
+// testEvent holds its payloads in interfaces
+type testEvent struct {
+	Kind    string `log:"kind"`
+	Payload interface{}
+	Items   []interface{}
+	Meta    map[string]interface{}
+}
+
+func TestAnyRedactsInterfaces(t *testing.T) {
+	bob := testUser{Name: "bob", Password: "hunter2"}
+	redacted := map[string]interface{}{"name": "bob", "password": Redacted}
+
+	got := encodeField(t, Any("event", testEvent{
+		Kind:    "login",
+		Payload: &bob,
+		Items:   []interface{}{bob, "plain", 1},
+		Meta:    map[string]interface{}{"user": bob, "n": 2},
+	}))
+	want := map[string]interface{}{
+		"kind":    "login",
+		"Payload": redacted,
+		"Items":   []interface{}{redacted, "plain", 1},
+		"Meta":    map[string]interface{}{"user": redacted, "n": 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("encoded %#v, want %#v", got, want)
+	}
+}
+
+// testNode may point to itself
+type testNode struct {
+	Name string `log:"name"`
+	Next *testNode
+}
+
+func TestAnyCycle(t *testing.T) {
+	n := &testNode{Name: "loop"}
+	n.Next = n
+
+	// the cycle fails the field rather than overflowing the stack
+	enc := zapcore.NewMapObjectEncoder()
+	Any("node", n).AddTo(enc)
+	if err, _ := enc.Fields["nodeError"].(string); !strings.Contains(err, "pointer cycle") {
+		t.Fatalf("fields %v, want the error of the cycle", enc.Fields)
+	}
+}
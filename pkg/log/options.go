@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"go.uber.org/zap/zapcore"
@@ -14,15 +15,54 @@ const (
 	flagLevel             = "logs.level"
 	flagDisableCaller     = "logs.disable-caller"
 	flagDisableStacktrace = "logs.disable-stacktrace"
+	flagStacktraceLevel   = "logs.stacktrace-level"
+	flagStacktraceDepth   = "logs.stacktrace-max-depth"
+	flagCallerSkip        = "logs.caller-skip"
 	flagFormat            = "logs.format"
 	flagEnableColor       = "logs.enable-color"
 	flagOutputPaths       = "logs.output-paths"
 	flagErrorOutputPaths  = "logs.error-output-paths"
+	flagLevelOutputPath   = "logs.level-output-path"
+	flagLevelOutputLevels = "logs.level-output-levels"
+	flagTenantField       = "logs.tenant-field"
+	flagTenantOutputPath  = "logs.tenant-output-path"
+	flagTenantLevels      = "logs.tenant-levels"
+	flagTenantMaxOpen     = "logs.tenant-max-open-files"
 	flagDevelopment       = "logs.development"
 	flagName              = "logs.name"
+	flagFatalExitCode     = "logs.fatal-exit-code"
+	flagFatalTimeout      = "logs.fatal-shutdown-timeout"
+	flagRingBufferSize    = "logs.ring-buffer-size"
+	flagRingBufferLevel   = "logs.ring-buffer-level"
+	flagCrashFile         = "logs.crash-file"
+	flagBufferMaxEntries  = "logs.request-buffer-max-entries"
+	flagBufferMaxBytes    = "logs.request-buffer-max-bytes"
+	flagVerbosity         = "logs.v"
+	flagVModule           = "logs.vmodule"
+	flagSinkPolicies      = "logs.sink-policies"
+	flagSinkRetryBuffer   = "logs.sink-retry-buffer"
+	flagSinkRetryBackoff  = "logs.sink-retry-max-backoff"
+	flagOTLPEndpoint      = "logs.otlp-endpoint"
+	flagOTLPProtocol      = "logs.otlp-protocol"
+	flagOTLPInsecure      = "logs.otlp-insecure"
+	flagOTLPHeaders       = "logs.otlp-headers"
+	flagOTLPResource      = "logs.otlp-resource-attributes"
+	flagOTLPBatchSize     = "logs.otlp-batch-size"
+	flagOTLPFlushInterval = "logs.otlp-flush-interval"
+	flagOTLPMaxRetries    = "logs.otlp-max-retries"
+	flagMaxMessageLength  = "logs.max-message-length"
+	flagMaxFieldLength    = "logs.max-field-length"
+	flagMaxArrayLength    = "logs.max-array-length"
+	flagMaxNestingDepth   = "logs.max-nesting-depth"
+	flagMaxEntrySize      = "logs.max-entry-size"
 
 	consoleFormat = "console" // txt
 	jsonFormat    = "json"
+	binaryFormat  = "binary" // read with BinaryReader
+	autoFormat    = "auto"   // console on a terminal, json otherwise
+
+	// TimeLayout is the layout of the timestamp of log entries
+	TimeLayout = "2006-01-02 15:04:05.000"
 
 	keyRequestID = "requestID"
 )
@@ -32,54 +72,256 @@ type Options struct {
 	OutputPaths       []string `json:"output-paths" mapstructure:"output-paths"`
 	ErrorOutputPaths  []string `json:"error-output-paths" mapstructure:"error-output-paths"`
 	Level             string   `json:"level" mapstructure:"level"`                   // log-level
-	Format            string   `json:"format" mapstructure:"format"`                 // log file output format, JSON or Console(txt)
+	Format            string   `json:"format" mapstructure:"format"`                 // log file output format, JSON, Console(txt), Binary or Auto
 	DisableCaller     bool     `json:"disable-caller" mapstructure:"disable-caller"` // show name,location and line No. of the funcation called
 	DisableStacktrace bool     `json:"disable-stacktrace" mapstructure:"disable-stacktrace"`
 	EnableColor       bool     `json:"enable-color" mapstructure:"enable-color"`
 	Development       bool     `json:"development" mapstructure:"development"`
 	Name              string   `json:"name" mapstructure:"name"`                   // logger name
 	CommonFields      []string `json:"common-fields" mapstructure:"common-fields"` // common log fields, eg: requestId, username
+	// LevelOutputPath is the template of the split files, such as /var/log/svc/{level}.log, empty disables them
+	LevelOutputPath string `json:"level-output-path" mapstructure:"level-output-path"`
+	// LevelOutputLevels are the levels of the split files, each file holds the levels up to the next one
+	LevelOutputLevels []string `json:"level-output-levels" mapstructure:"level-output-levels"`
+	// TenantField is the field routing the entries to the sinks of their tenants, empty disables the routing.
+	// The entries of the tenants skip the sampling, the OTLP export and the files split by level.
+	TenantField string `json:"tenant-field" mapstructure:"tenant-field"`
+	// TenantOutputPath is the template of the tenant sinks, such as /var/log/svc/{tenant}.log
+	TenantOutputPath string `json:"tenant-output-path" mapstructure:"tenant-output-path"`
+	// TenantLevels override the level of the tenants, TENANT=LEVEL
+	TenantLevels map[string]string `json:"tenant-levels" mapstructure:"tenant-levels"`
+	// TenantMaxOpenFiles caps the open tenant sinks, the least recently used ones are closed
+	TenantMaxOpenFiles int `json:"tenant-max-open-files" mapstructure:"tenant-max-open-files"`
+	// StacktraceLevel is the minimum level of the entries carrying a stack trace, panic by default and warn in development
+	StacktraceLevel string `json:"stacktrace-level" mapstructure:"stacktrace-level"`
+	// StacktraceMaxDepth caps the frames of a stack trace, 0 means no limit
+	StacktraceMaxDepth int `json:"stacktrace-max-depth" mapstructure:"stacktrace-max-depth"`
+	// CallerSkip is the number of wrapper frames skipped to report the caller
+	CallerSkip int `json:"caller-skip" mapstructure:"caller-skip"`
+	// FatalExitCode is the process exit code after a fatal log, 0 means 1
+	FatalExitCode int `json:"fatal-exit-code" mapstructure:"fatal-exit-code"`
+	// FatalShutdownTimeout bounds the graceful shutdown triggered by a fatal log
+	FatalShutdownTimeout time.Duration `json:"fatal-shutdown-timeout" mapstructure:"fatal-shutdown-timeout"`
+	// Shutdowner is triggered before exiting on a fatal log, optional
+	Shutdowner Shutdowner `json:"-" mapstructure:"-"`
+	// RingBufferSize is the number of recent entries kept in memory, 0 disables it
+	RingBufferSize int `json:"ring-buffer-size" mapstructure:"ring-buffer-size"`
+	// RingBufferLevel is the minimum level of the entries kept in memory
+	RingBufferLevel string `json:"ring-buffer-level" mapstructure:"ring-buffer-level"`
+	// CrashFile receives the entries kept in memory on panic or fatal logs, and dpanic logs in development
+	CrashFile string `json:"crash-file" mapstructure:"crash-file"`
+	// RequestBufferMaxEntries caps the entries a request buffers, 0 means no limit
+	RequestBufferMaxEntries int `json:"request-buffer-max-entries" mapstructure:"request-buffer-max-entries"`
+	// RequestBufferMaxBytes caps the memory a request buffers, 0 means no limit
+	RequestBufferMaxBytes int `json:"request-buffer-max-bytes" mapstructure:"request-buffer-max-bytes"`
+	// Verbosity is the highest V level logged
+	Verbosity int `json:"v" mapstructure:"v"`
+	// VModule overrides Verbosity by logger name, NAME=LEVEL
+	VModule []string `json:"vmodule" mapstructure:"vmodule"`
+	// SinkPolicies are the failure policies by output path: none, drop, retry or failover:PATH.
+	// The policy of TenantOutputPath applies to the sinks of the tenants, PATH may have {tenant}.
+	SinkPolicies map[string]string `json:"sink-policies" mapstructure:"sink-policies"`
+	// SinkRetryBuffer caps the entries a sink with the retry policy buffers
+	SinkRetryBuffer int `json:"sink-retry-buffer" mapstructure:"sink-retry-buffer"`
+	// SinkRetryMaxBackoff is the longest backoff between the retries of a sink
+	SinkRetryMaxBackoff time.Duration `json:"sink-retry-max-backoff" mapstructure:"sink-retry-max-backoff"`
+	// OTLPEndpoint is the OpenTelemetry collector entries are exported to, empty disables it
+	OTLPEndpoint string `json:"otlp-endpoint" mapstructure:"otlp-endpoint"`
+	// OTLPProtocol is the transport of the exports, grpc or http/protobuf
+	OTLPProtocol string `json:"otlp-protocol" mapstructure:"otlp-protocol"`
+	// OTLPInsecure disables TLS for the exports
+	OTLPInsecure bool `json:"otlp-insecure" mapstructure:"otlp-insecure"`
+	// OTLPHeaders are sent with the exports, such as authentication headers
+	OTLPHeaders map[string]string `json:"otlp-headers" mapstructure:"otlp-headers"`
+	// OTLPResourceAttributes describe the service, service.name defaults to Name
+	OTLPResourceAttributes map[string]string `json:"otlp-resource-attributes" mapstructure:"otlp-resource-attributes"`
+	// OTLPBatchSize is the number of entries exported together
+	OTLPBatchSize int `json:"otlp-batch-size" mapstructure:"otlp-batch-size"`
+	// OTLPFlushInterval is the longest time an entry waits to be exported
+	OTLPFlushInterval time.Duration `json:"otlp-flush-interval" mapstructure:"otlp-flush-interval"`
+	// OTLPMaxRetries is the number of retries of a failed export
+	OTLPMaxRetries int `json:"otlp-max-retries" mapstructure:"otlp-max-retries"`
+	// MaxMessageLength caps the bytes of a message, 0 means no limit
+	MaxMessageLength int `json:"max-message-length" mapstructure:"max-message-length"`
+	// MaxFieldLength caps the bytes of a string, bytes or reflected field, 0 means no limit
+	MaxFieldLength int `json:"max-field-length" mapstructure:"max-field-length"`
+	// MaxArrayLength caps the elements of an array field, 0 means no limit
+	MaxArrayLength int `json:"max-array-length" mapstructure:"max-array-length"`
+	// MaxNestingDepth caps the nesting of objects and arrays, 0 means no limit
+	MaxNestingDepth int `json:"max-nesting-depth" mapstructure:"max-nesting-depth"`
+	// MaxEntrySize caps the bytes of an encoded entry, its fields are dropped beyond, 0 means no limit
+	MaxEntrySize int `json:"max-entry-size" mapstructure:"max-entry-size"`
 }
 
 func NewOptions() *Options {
 	return &Options{
-		Level:             zapcore.InfoLevel.String(),
-		DisableCaller:     false,
-		DisableStacktrace: false,
-		Format:            consoleFormat,
-		EnableColor:       true,
-		Development:       false,
-		OutputPaths:       []string{os.Stdout.Name()},
-		ErrorOutputPaths:  []string{os.Stderr.Name()},
-		CommonFields:      []string{keyRequestID},
+		Level:                   zapcore.InfoLevel.String(),
+		DisableCaller:           false,
+		DisableStacktrace:       false,
+		Format:                  consoleFormat,
+		EnableColor:             true,
+		Development:             false,
+		OutputPaths:             []string{os.Stdout.Name()},
+		ErrorOutputPaths:        []string{os.Stderr.Name()},
+		LevelOutputLevels:       []string{"info", "warn", "error"},
+		TenantMaxOpenFiles:      100,
+		CommonFields:            []string{keyRequestID},
+		FatalExitCode:           1,
+		FatalShutdownTimeout:    10 * time.Second,
+		RingBufferLevel:         zapcore.DebugLevel.String(),
+		RequestBufferMaxEntries: 1000,
+		RequestBufferMaxBytes:   1 << 20,
+		SinkRetryBuffer:         1000,
+		SinkRetryMaxBackoff:     30 * time.Second,
+		OTLPProtocol:            OTLPProtocolGRPC,
+		OTLPBatchSize:           512,
+		OTLPFlushInterval:       5 * time.Second,
+		OTLPMaxRetries:          5,
 	}
 }
 
 func (o *Options) Validate() []error {
 	var errs []error
 
-	var zapLevel zapcore.Level
-	if err := zapLevel.UnmarshalText([]byte(o.Level)); err != nil {
+	if _, err := ParseLevel(o.Level); err != nil {
 		errs = append(errs, err)
 	}
 
+	if o.StacktraceLevel != "" {
+		if _, err := ParseLevel(o.StacktraceLevel); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if o.StacktraceMaxDepth < 0 || o.CallerSkip < 0 {
+		errs = append(errs, fmt.Errorf("stacktrace max depth and caller skip must not be negative: %d, %d",
+			o.StacktraceMaxDepth, o.CallerSkip))
+	}
+
 	format := strings.ToLower(o.Format)
-	if format != consoleFormat && format != jsonFormat {
+	if format != consoleFormat && format != jsonFormat && format != binaryFormat && format != autoFormat {
 		errs = append(errs, fmt.Errorf("not a valid log format: %q", o.Format))
 	}
 
+	if o.RingBufferSize < 0 {
+		errs = append(errs, fmt.Errorf("ring buffer size must not be negative: %d", o.RingBufferSize))
+	}
+
+	if o.RingBufferSize > 0 {
+		if _, err := ParseLevel(o.RingBufferLevel); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if o.RequestBufferMaxEntries < 0 || o.RequestBufferMaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("request buffer limits must not be negative: %d entries, %d bytes",
+			o.RequestBufferMaxEntries, o.RequestBufferMaxBytes))
+	}
+
+	if _, err := newVerbosity(o.Verbosity, o.VModule); err != nil {
+		errs = append(errs, err)
+	}
+
+	if o.FatalExitCode < 0 || o.FatalExitCode > 125 {
+		errs = append(errs, fmt.Errorf("not a valid fatal exit code: %d", o.FatalExitCode))
+	}
+
+	if o.FatalShutdownTimeout < 0 {
+		errs = append(errs, fmt.Errorf("fatal shutdown timeout must not be negative: %v", o.FatalShutdownTimeout))
+	}
+
+	levelOutputs, err := o.levelOutputs()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if o.TenantField != "" {
+		if !strings.Contains(o.TenantOutputPath, TenantPlaceholder) {
+			errs = append(errs, fmt.Errorf("tenant output path without %s: %q", TenantPlaceholder, o.TenantOutputPath))
+		}
+
+		if o.TenantMaxOpenFiles < 1 {
+			errs = append(errs, fmt.Errorf("tenant max open files must be positive: %d", o.TenantMaxOpenFiles))
+		}
+
+		for tenant, level := range o.TenantLevels {
+			if _, err := ParseLevel(level); err != nil {
+				errs = append(errs, fmt.Errorf("level of tenant %q: %w", tenant, err))
+			}
+		}
+	}
+
+	for path, policy := range o.SinkPolicies {
+		tenantPath := o.TenantField != "" && path == o.TenantOutputPath
+		if !contains(o.OutputPaths, path) && !containsLevelOutput(levelOutputs, path) && !tenantPath {
+			errs = append(errs, fmt.Errorf("sink policy of an unknown output path: %q", path))
+		}
+
+		if _, _, err := parseSinkPolicy(policy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if o.SinkRetryBuffer < 1 {
+		errs = append(errs, fmt.Errorf("sink retry buffer must be positive: %d", o.SinkRetryBuffer))
+	}
+
+	if o.SinkRetryMaxBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("sink retry max backoff must be positive: %v", o.SinkRetryMaxBackoff))
+	}
+
+	if o.MaxMessageLength < 0 || o.MaxFieldLength < 0 || o.MaxArrayLength < 0 || o.MaxNestingDepth < 0 ||
+		o.MaxEntrySize < 0 {
+		errs = append(errs, fmt.Errorf("size limits must not be negative: %d message, %d field, %d array, "+
+			"%d depth, %d entry", o.MaxMessageLength, o.MaxFieldLength, o.MaxArrayLength, o.MaxNestingDepth,
+			o.MaxEntrySize))
+	}
+
+	if o.OTLPEndpoint != "" {
+		if protocol := strings.ToLower(o.OTLPProtocol); protocol != OTLPProtocolGRPC && protocol != OTLPProtocolHTTP {
+			errs = append(errs, fmt.Errorf("not a valid otlp protocol: %q", o.OTLPProtocol))
+		}
+
+		if o.OTLPBatchSize < 1 || o.OTLPFlushInterval <= 0 || o.OTLPMaxRetries < 0 {
+			errs = append(errs, fmt.Errorf("otlp batch size and flush interval must be positive and retries not negative: "+
+				"%d entries, %v, %d retries", o.OTLPBatchSize, o.OTLPFlushInterval, o.OTLPMaxRetries))
+		}
+	}
+
 	return errs
 }
 
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
-	fs.StringVar(&o.Level, flagLevel, o.Level, "Minimum log output `LEVEL`.")
+	fs.StringVar(&o.Level, flagLevel, o.Level,
+		"Minimum log output `LEVEL`: trace, debug, info, notice, warn, error, dpanic, panic or fatal.")
 	fs.BoolVar(&o.DisableCaller, flagDisableCaller, o.DisableCaller, "Disable output of caller information in the log.")
 	fs.BoolVar(&o.DisableStacktrace, flagDisableStacktrace,
-		o.DisableStacktrace, "Disable the log to record a stack trace for all messages at or above panic level.")
-	fs.StringVar(&o.Format, flagFormat, o.Format, "Log output `FORMAT`, support plain or json format.")
-	fs.BoolVar(&o.EnableColor, flagEnableColor, o.EnableColor, "Enable output ansi colors in plain format logs.")
+		o.DisableStacktrace, "Disable the log to record a stack trace for all messages at or above the stacktrace level.")
+	fs.StringVar(&o.StacktraceLevel, flagStacktraceLevel, o.StacktraceLevel,
+		"Minimum `LEVEL` of the log entries carrying a stack trace, panic by default and warn in development.")
+	fs.IntVar(&o.StacktraceMaxDepth, flagStacktraceDepth, o.StacktraceMaxDepth,
+		"Maximum frames of a stack trace, runtime and log internals excluded, 0 means no limit.")
+	fs.IntVar(&o.CallerSkip, flagCallerSkip, o.CallerSkip,
+		"Number of wrapper frames skipped to report the caller of a log entry.")
+	fs.StringVar(&o.Format, flagFormat, o.Format,
+		"Log output `FORMAT`, support plain, json, binary or auto, plain on a terminal and json otherwise or in containers.")
+	fs.BoolVar(&o.EnableColor, flagEnableColor, o.EnableColor,
+		"Enable output ansi colors in plain format logs, when stdout is a terminal.")
 	fs.StringSliceVar(&o.OutputPaths, flagOutputPaths, o.OutputPaths, "Output paths of log.")
-	fs.StringSliceVar(&o.ErrorOutputPaths, flagErrorOutputPaths, o.ErrorOutputPaths, "Error output paths of log.")
+	fs.StringSliceVar(&o.ErrorOutputPaths, flagErrorOutputPaths, o.ErrorOutputPaths,
+		"Error output paths of log, {level} is replaced by error.")
+	fs.StringVar(&o.LevelOutputPath, flagLevelOutputPath, o.LevelOutputPath,
+		"Template of the files split by level, such as /var/log/svc/{level}.log, empty disables them.")
+	fs.StringSliceVar(&o.LevelOutputLevels, flagLevelOutputLevels, o.LevelOutputLevels,
+		"Levels of the files split by level, each file holds the entries up to the level of the next one.")
+	fs.StringVar(&o.TenantField, flagTenantField, o.TenantField,
+		"`FIELD` routing the log entries to the files of their tenants, such as tenant, empty disables the routing.")
+	fs.StringVar(&o.TenantOutputPath, flagTenantOutputPath, o.TenantOutputPath,
+		"Template of the tenant files, such as /var/log/svc/{tenant}.log, untagged entries go to the output paths.")
+	fs.StringToStringVar(&o.TenantLevels, flagTenantLevels, o.TenantLevels,
+		"Levels of the tenants overriding the log level, TENANT=LEVEL.")
+	fs.IntVar(&o.TenantMaxOpenFiles, flagTenantMaxOpen, o.TenantMaxOpenFiles,
+		"Maximum number of open tenant files, the least recently used ones are closed.")
 	fs.BoolVar(
 		&o.Development,
 		flagDevelopment,
@@ -88,6 +330,71 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 			"the behavior of DPanicLevel and takes stacktraces more liberally.",
 	)
 	fs.StringVar(&o.Name, flagName, o.Name, "The name of the logger.")
+	fs.IntVar(&o.Verbosity, flagVerbosity, o.Verbosity, "Highest verbosity `LEVEL` logged by V.")
+	fs.StringSliceVar(&o.VModule, flagVModule, o.VModule,
+		"Verbosity levels by logger name, NAME=LEVEL, NAME may be a pattern such as db.*.")
+	fs.IntVar(&o.FatalExitCode, flagFatalExitCode, o.FatalExitCode, "Exit code of the process after a fatal log.")
+	fs.DurationVar(&o.FatalShutdownTimeout, flagFatalTimeout, o.FatalShutdownTimeout,
+		"Maximum time the graceful shutdown triggered by a fatal log may take, 0 means no limit.")
+	fs.IntVar(&o.RingBufferSize, flagRingBufferSize, o.RingBufferSize,
+		"Number of recent log entries kept in memory for diagnostics, 0 disables it.")
+	fs.StringVar(&o.RingBufferLevel, flagRingBufferLevel, o.RingBufferLevel,
+		"Minimum `LEVEL` of the log entries kept in memory, may be lower than the output level.")
+	fs.StringVar(&o.CrashFile, flagCrashFile, o.CrashFile,
+		"File the log entries kept in memory are dumped to on panic or fatal logs, and dpanic logs in development.")
+	fs.IntVar(&o.RequestBufferMaxEntries, flagBufferMaxEntries, o.RequestBufferMaxEntries,
+		"Maximum number of log entries a buffered request holds in memory, 0 means no limit.")
+	fs.IntVar(&o.RequestBufferMaxBytes, flagBufferMaxBytes, o.RequestBufferMaxBytes,
+		"Maximum memory in bytes a buffered request holds, 0 means no limit.")
+	fs.StringToStringVar(&o.SinkPolicies, flagSinkPolicies, o.SinkPolicies,
+		"Failure policies of the output paths, PATH=POLICY, POLICY is none, drop, retry or failover:PATH.")
+	fs.IntVar(&o.SinkRetryBuffer, flagSinkRetryBuffer, o.SinkRetryBuffer,
+		"Maximum number of log entries an output path with the retry policy buffers.")
+	fs.DurationVar(&o.SinkRetryMaxBackoff, flagSinkRetryBackoff, o.SinkRetryMaxBackoff,
+		"Longest backoff between the retries of an output path with the retry policy.")
+	fs.StringVar(&o.OTLPEndpoint, flagOTLPEndpoint, o.OTLPEndpoint,
+		"OpenTelemetry collector `ENDPOINT` log entries are exported to, empty disables the export.")
+	fs.StringVar(&o.OTLPProtocol, flagOTLPProtocol, o.OTLPProtocol,
+		"Transport `PROTOCOL` of the export, grpc or http/protobuf.")
+	fs.BoolVar(&o.OTLPInsecure, flagOTLPInsecure, o.OTLPInsecure, "Export log entries without TLS.")
+	fs.StringToStringVar(&o.OTLPHeaders, flagOTLPHeaders, o.OTLPHeaders,
+		"Headers sent with the exports, NAME=VALUE.")
+	fs.StringToStringVar(&o.OTLPResourceAttributes, flagOTLPResource, o.OTLPResourceAttributes,
+		"Resource attributes of the exported log entries, NAME=VALUE, service.name defaults to the logger name.")
+	fs.IntVar(&o.OTLPBatchSize, flagOTLPBatchSize, o.OTLPBatchSize, "Number of log entries exported together.")
+	fs.DurationVar(&o.OTLPFlushInterval, flagOTLPFlushInterval, o.OTLPFlushInterval,
+		"Longest time a log entry waits to be exported.")
+	fs.IntVar(&o.OTLPMaxRetries, flagOTLPMaxRetries, o.OTLPMaxRetries, "Number of retries of a failed export.")
+	fs.IntVar(&o.MaxMessageLength, flagMaxMessageLength, o.MaxMessageLength,
+		"Maximum bytes of a log message, longer ones are truncated, 0 means no limit.")
+	fs.IntVar(&o.MaxFieldLength, flagMaxFieldLength, o.MaxFieldLength,
+		"Maximum bytes of a string field, longer ones are truncated, 0 means no limit.")
+	fs.IntVar(&o.MaxArrayLength, flagMaxArrayLength, o.MaxArrayLength,
+		"Maximum elements of an array field, longer ones are truncated, 0 means no limit.")
+	fs.IntVar(&o.MaxNestingDepth, flagMaxNestingDepth, o.MaxNestingDepth,
+		"Maximum nesting depth of object and array fields, deeper ones are truncated, 0 means no limit.")
+	fs.IntVar(&o.MaxEntrySize, flagMaxEntrySize, o.MaxEntrySize,
+		"Maximum bytes of an encoded log entry, the fields of larger ones are dropped, 0 means no limit.")
+}
+
+func containsLevelOutput(outputs []levelOutput, path string) bool {
+	for _, output := range outputs {
+		if output.path == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (o *Options) String() string {
@@ -0,0 +1,716 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Transport protocols of the OTLP exporter
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http/protobuf"
+)
+
+const (
+	// otlpScope is the instrumentation scope of the exported log records
+	otlpScope = "git.enn-edge.com/device_manage/public/log.git"
+	// otlpHTTPPath is the path logs are posted to when the endpoint has none
+	otlpHTTPPath = "/v1/logs"
+	// otlpRetryBackoff is the first backoff between export retries
+	otlpRetryBackoff = 500 * time.Millisecond
+	// otlpMaxRetryBackoff is the longest backoff between export retries
+	otlpMaxRetryBackoff = 30 * time.Second
+	// otlpExportTimeout bounds a single export request
+	otlpExportTimeout = 10 * time.Second
+	// otlpQueueBatches is the number of batches queued before dropping records
+	otlpQueueBatches = 8
+)
+
+// otlpSyncTimeout bounds the exports of the Sync and Close of a logger,
+// whose records are otherwise retried for minutes by an unavailable
+// collector
+var otlpSyncTimeout = 5 * time.Second
+
+// OTLPOptions configures an OTLPExporter.
+type OTLPOptions struct {
+	// Endpoint is host:port for grpc, a URL or host:port for http/protobuf
+	Endpoint string
+	// Protocol is OTLPProtocolGRPC or OTLPProtocolHTTP
+	Protocol string
+	// Insecure disables TLS
+	Insecure bool
+	// Headers are sent with every export request
+	Headers map[string]string
+	// ResourceAttributes describe the entity producing the logs
+	ResourceAttributes map[string]string
+	// BatchSize is the number of records exported together
+	BatchSize int
+	// FlushInterval is the longest time a record waits to be exported
+	FlushInterval time.Duration
+	// MaxRetries is the number of retries of a failed export
+	MaxRetries int
+	// ErrorOutput receives the errors of the exports, stderr if nil
+	ErrorOutput zapcore.WriteSyncer
+}
+
+// OTLPExporter exports log entries to an OpenTelemetry collector in
+// batches, over OTLP/gRPC or OTLP/HTTP with protobuf payloads.
+type OTLPExporter struct {
+	opts     OTLPOptions
+	resource *resourcepb.Resource
+	client   otlpClient
+
+	mu      sync.Mutex
+	records []*logspb.LogRecord
+	dropped uint64
+
+	// exporting serializes the flushes so that batches keep their order,
+	// it is a channel so that the flushes can give up waiting
+	exporting chan struct{}
+	flushc    chan struct{}
+	done      chan struct{}
+	stopped   sync.WaitGroup
+	stopOnce  sync.Once
+	// ctx of the background exports, canceled by Shutdown past its
+	// deadline
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// otlpClient sends an export request, retryable reports whether a failed
+// request may be sent again
+type otlpClient interface {
+	export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error
+	retryable(err error) bool
+	close() error
+}
+
+// NewOTLPExporter creates an exporter and starts exporting its batches in
+// the background until Shutdown.
+func NewOTLPExporter(opts OTLPOptions) (*OTLPExporter, error) {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.ErrorOutput == nil {
+		opts.ErrorOutput = zapcore.Lock(os.Stderr)
+	}
+
+	var (
+		client otlpClient
+		err    error
+	)
+	switch strings.ToLower(opts.Protocol) {
+	case OTLPProtocolGRPC:
+		client, err = newOTLPGRPCClient(opts)
+	case OTLPProtocolHTTP, "":
+		client, err = newOTLPHTTPClient(opts)
+	default:
+		err = fmt.Errorf("not a valid otlp protocol: %q", opts.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e := &OTLPExporter{
+		opts:      opts,
+		resource:  &resourcepb.Resource{Attributes: stringAttributes(opts.ResourceAttributes)},
+		client:    client,
+		flushc:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		exporting: make(chan struct{}, 1),
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+
+	e.stopped.Add(1)
+	go e.run()
+
+	return e, nil
+}
+
+// newOptionsExporter creates the exporter of the OTLP options of New
+func newOptionsExporter(opts *Options, errorOutput zapcore.WriteSyncer) (*OTLPExporter, error) {
+	resource := map[string]string{}
+	if opts.Name != "" {
+		resource["service.name"] = opts.Name
+	}
+	for k, v := range opts.OTLPResourceAttributes {
+		resource[k] = v
+	}
+
+	return NewOTLPExporter(OTLPOptions{
+		Endpoint:           opts.OTLPEndpoint,
+		Protocol:           opts.OTLPProtocol,
+		Insecure:           opts.OTLPInsecure,
+		Headers:            opts.OTLPHeaders,
+		ResourceAttributes: resource,
+		BatchSize:          opts.OTLPBatchSize,
+		FlushInterval:      opts.OTLPFlushInterval,
+		MaxRetries:         opts.OTLPMaxRetries,
+		ErrorOutput:        errorOutput,
+	})
+}
+
+// OTLPExporter returns the exporter of the OTLP options of the logger, nil
+// if the entries aren't exported. Close shuts it down.
+func (l *zapLogger) OTLPExporter() *OTLPExporter {
+	return l.exporter
+}
+
+// Core returns a core converting the entries enabled by enab to log
+// records of the exporter.
+func (e *OTLPExporter) Core(enab zapcore.LevelEnabler) zapcore.Core {
+	return &otlpCore{LevelEnabler: enab, exporter: e}
+}
+
+// Dropped returns the number of records dropped, because the queue was
+// full or their export failed.
+func (e *OTLPExporter) Dropped() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.dropped
+}
+
+// Flush exports the queued records.
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	// the records are taken under the export lock to keep their order
+	select {
+	case e.exporting <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-e.exporting }()
+
+	e.mu.Lock()
+	records := e.records
+	e.records = nil
+	e.mu.Unlock()
+
+	return e.export(ctx, records)
+}
+
+// Shutdown stops the background exports, exports the queued records and
+// closes the connection to the collector. The export in progress is
+// abandoned once ctx is done.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	defer e.cancel()
+
+	e.stopOnce.Do(func() {
+		close(e.done)
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		e.stopped.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		e.cancel()
+		<-stopped
+	}
+
+	err := e.Flush(ctx)
+	if cerr := e.client.close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (e *OTLPExporter) enqueue(record *logspb.LogRecord) {
+	e.mu.Lock()
+	if len(e.records) >= e.opts.BatchSize*otlpQueueBatches {
+		e.dropped++
+		e.mu.Unlock()
+
+		return
+	}
+	e.records = append(e.records, record)
+	full := len(e.records) >= e.opts.BatchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flushc <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *OTLPExporter) run() {
+	defer e.stopped.Done()
+
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		case <-e.flushc:
+		}
+
+		if err := e.Flush(e.ctx); err != nil {
+			fmt.Fprintf(e.opts.ErrorOutput, "%v otlp export error: %v\n", time.Now(), err)
+			_ = e.opts.ErrorOutput.Sync()
+		}
+	}
+}
+
+// export sends the records in batches, retrying the failed ones with an
+// exponential backoff
+func (e *OTLPExporter) export(ctx context.Context, records []*logspb.LogRecord) error {
+	for len(records) > 0 {
+		n := len(records)
+		if n > e.opts.BatchSize {
+			n = e.opts.BatchSize
+		}
+		batch := records[:n]
+		records = records[n:]
+
+		if err := e.exportBatch(ctx, batch); err != nil {
+			e.mu.Lock()
+			e.dropped += uint64(len(batch) + len(records))
+			e.mu.Unlock()
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *OTLPExporter) exportBatch(ctx context.Context, batch []*logspb.LogRecord) error {
+	req := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: e.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: otlpScope},
+				LogRecords: batch,
+			}},
+		}},
+	}
+
+	backoff := otlpRetryBackoff
+	for attempt := 0; ; attempt++ {
+		exportCtx, cancel := context.WithTimeout(ctx, otlpExportTimeout)
+		err := e.client.export(exportCtx, req)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= e.opts.MaxRetries || !e.client.retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > otlpMaxRetryBackoff {
+			backoff = otlpMaxRetryBackoff
+		}
+	}
+}
+
+// otlpCore converts the entries to OTLP log records.
+type otlpCore struct {
+	zapcore.LevelEnabler
+	exporter *OTLPExporter
+	fields   []zapcore.Field
+}
+
+func (c *otlpCore) With(fields []zapcore.Field) zapcore.Core {
+	return &otlpCore{
+		LevelEnabler: c.LevelEnabler,
+		exporter:     c.exporter,
+		fields:       append(append([]zapcore.Field(nil), c.fields...), fields...),
+	}
+}
+
+func (c *otlpCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *otlpCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(ent.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       otlpSeverity(ent.Level),
+		SeverityText:         strings.ToUpper(LevelString(ent.Level)),
+		Body:                 stringValue(ent.Message),
+	}
+
+	// the span fields added by J are the trace context of the record
+	if id, ok := enc.Fields["trace_id"].(string); ok {
+		if record.TraceId = decodeID(id, 16); record.TraceId != nil {
+			delete(enc.Fields, "trace_id")
+		}
+	}
+	if id, ok := enc.Fields["span_id"].(string); ok {
+		if record.SpanId = decodeID(id, 8); record.SpanId != nil {
+			delete(enc.Fields, "span_id")
+		}
+	}
+
+	if ent.LoggerName != "" {
+		enc.Fields["logger.name"] = ent.LoggerName
+	}
+	if ent.Caller.Defined {
+		enc.Fields["code.filepath"] = ent.Caller.File
+		enc.Fields["code.lineno"] = ent.Caller.Line
+		if ent.Caller.Function != "" {
+			enc.Fields["code.function"] = ent.Caller.Function
+		}
+	}
+	if ent.Stack != "" {
+		enc.Fields["exception.stacktrace"] = ent.Stack
+	}
+
+	record.Attributes = attributes(enc.Fields)
+	c.exporter.enqueue(record)
+
+	return nil
+}
+
+// Sync exports the queued records, those not exported before
+// otlpSyncTimeout are dropped
+func (c *otlpCore) Sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), otlpSyncTimeout)
+	defer cancel()
+
+	return c.exporter.Flush(ctx)
+}
+
+// otlpSeverity maps the levels to the severity numbers of the OTLP data
+// model
+func otlpSeverity(lvl zapcore.Level) logspb.SeverityNumber {
+	switch lvl {
+	case TraceLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_TRACE
+	case zapcore.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case zapcore.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case NoticeLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO2
+	case zapcore.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case zapcore.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case zapcore.DPanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR2
+	case zapcore.PanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR3
+	case zapcore.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	}
+
+	return logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED
+}
+
+// decodeID decodes a hex trace or span ID, left padded to size bytes, it is
+// nil if the ID isn't valid
+func decodeID(s string, size int) []byte {
+	if len(s) > size*2 {
+		return nil
+	}
+
+	id, err := hex.DecodeString(strings.Repeat("0", size*2-len(s)) + s)
+	if err != nil {
+		return nil
+	}
+
+	return id
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+func stringAttributes(m map[string]string) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(m))
+	for k, v := range m {
+		kvs = append(kvs, &commonpb.KeyValue{Key: k, Value: stringValue(v)})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+
+	return kvs
+}
+
+// attributes converts the fields collected by a map encoder, sorted by key
+func attributes(m map[string]interface{}) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(m))
+	for k, v := range m {
+		kvs = append(kvs, &commonpb.KeyValue{Key: k, Value: anyValue(v)})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+
+	return kvs
+}
+
+// anyValue converts the values collected by a map encoder
+func anyValue(v interface{}) *commonpb.AnyValue {
+	switch v := v.(type) {
+	case nil:
+		return &commonpb.AnyValue{}
+	case string:
+		return stringValue(v)
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return intValue(int64(v))
+	case int8:
+		return intValue(int64(v))
+	case int16:
+		return intValue(int64(v))
+	case int32:
+		return intValue(int64(v))
+	case int64:
+		return intValue(v)
+	case uint:
+		return uintValue(uint64(v))
+	case uint8:
+		return intValue(int64(v))
+	case uint16:
+		return intValue(int64(v))
+	case uint32:
+		return intValue(int64(v))
+	case uint64:
+		return uintValue(v)
+	case uintptr:
+		return uintValue(uint64(v))
+	case float32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(v)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case []byte:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: v}}
+	case time.Time:
+		return stringValue(v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return stringValue(v.String())
+	case error:
+		return stringValue(v.Error())
+	case map[string]interface{}:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{
+			KvlistValue: &commonpb.KeyValueList{Values: attributes(v)},
+		}}
+	case []interface{}:
+		values := make([]*commonpb.AnyValue, len(v))
+		for i, e := range v {
+			values[i] = anyValue(e)
+		}
+
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{
+			ArrayValue: &commonpb.ArrayValue{Values: values},
+		}}
+	case fmt.Stringer:
+		return stringValue(v.String())
+	}
+
+	return stringValue(fmt.Sprint(v))
+}
+
+func intValue(v int64) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+}
+
+// uintValue converts the unsigned integers too large for an int value to
+// strings
+func uintValue(v uint64) *commonpb.AnyValue {
+	if v > math.MaxInt64 {
+		return stringValue(strconv.FormatUint(v, 10))
+	}
+
+	return intValue(int64(v))
+}
+
+// otlpHTTPClient posts the export requests as protobuf over HTTP
+type otlpHTTPClient struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newOTLPHTTPClient(opts OTLPOptions) (*otlpHTTPClient, error) {
+	endpoint := opts.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		scheme := "https://"
+		if opts.Insecure {
+			scheme = "http://"
+		}
+		endpoint = scheme + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid otlp endpoint: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpHTTPPath
+	}
+
+	return &otlpHTTPClient{
+		url:     u.String(),
+		headers: opts.Headers,
+		client:  &http.Client{},
+	}, nil
+}
+
+// otlpHTTPError is an export rejected by the collector
+type otlpHTTPError struct {
+	code int
+	body string
+}
+
+func (e *otlpHTTPError) Error() string {
+	return fmt.Sprintf("otlp collector responded %d: %s", e.code, e.body)
+}
+
+func (c *otlpHTTPClient) export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range c.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &otlpHTTPError{code: resp.StatusCode, body: string(respBody)}
+	}
+
+	return nil
+}
+
+// retryable reports the transport errors and the responses the OTLP
+// specification allows to retry
+func (c *otlpHTTPClient) retryable(err error) bool {
+	var httpErr *otlpHTTPError
+	if !errors.As(err, &httpErr) {
+		return true
+	}
+
+	switch httpErr.code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+func (c *otlpHTTPClient) close() error {
+	c.client.CloseIdleConnections()
+
+	return nil
+}
+
+// otlpGRPCClient calls the logs service of the collector over gRPC
+type otlpGRPCClient struct {
+	conn    *grpc.ClientConn
+	client  collogspb.LogsServiceClient
+	headers metadata.MD
+}
+
+func newOTLPGRPCClient(opts OTLPOptions) (*otlpGRPCClient, error) {
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if opts.Insecure {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.Dial(opts.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	return &otlpGRPCClient{
+		conn:    conn,
+		client:  collogspb.NewLogsServiceClient(conn),
+		headers: metadata.New(opts.Headers),
+	}, nil
+}
+
+func (c *otlpGRPCClient) export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	_, err := c.client.Export(metadata.NewOutgoingContext(ctx, c.headers), req)
+
+	return err
+}
+
+// retryable reports the status codes the OTLP specification allows to retry
+func (c *otlpGRPCClient) retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+		codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+
+	return false
+}
+
+func (c *otlpGRPCClient) close() error {
+	return c.conn.Close()
+}
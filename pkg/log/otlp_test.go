@@ -0,0 +1,174 @@
+package log
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// testCollector is an OTLP/HTTP collector responding with status, or
+// blocking until the test ends while block is set
+type testCollector struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	status int
+	block  chan struct{}
+	bodies []string
+}
+
+func newTestCollector(t *testing.T, status int) *testCollector {
+	t.Helper()
+
+	c := &testCollector{status: status}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.block != nil {
+			select {
+			case <-c.block:
+			case <-r.Context().Done():
+			}
+
+			return
+		}
+
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		var req collogspb.ExportLogsServiceRequest
+		if err := proto.Unmarshal(data, &req); err != nil {
+			t.Error(err)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.status == http.StatusOK {
+			for _, rl := range req.ResourceLogs {
+				for _, sl := range rl.ScopeLogs {
+					for _, record := range sl.LogRecords {
+						c.bodies = append(c.bodies, record.Body.GetStringValue())
+					}
+				}
+			}
+		}
+		w.WriteHeader(c.status)
+	}))
+	t.Cleanup(c.Close)
+
+	return c
+}
+
+func (c *testCollector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.bodies...)
+}
+
+// newOTLPLogger returns a logger exporting its entries to the collector
+func newOTLPLogger(t *testing.T, c *testCollector, maxRetries int) *zapLogger {
+	t.Helper()
+
+	opts := newTestOptions(t)
+	opts.OTLPEndpoint = c.URL
+	opts.OTLPProtocol = OTLPProtocolHTTP
+	opts.OTLPInsecure = true
+	opts.OTLPBatchSize = 100
+	opts.OTLPFlushInterval = time.Hour
+	opts.OTLPMaxRetries = maxRetries
+	l, _ := newTestLogger(t, opts)
+
+	return l
+}
+
+// shortenOTLPSyncTimeout sets otlpSyncTimeout for a test
+func shortenOTLPSyncTimeout(t *testing.T) {
+	timeout := otlpSyncTimeout
+	otlpSyncTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { otlpSyncTimeout = timeout })
+}
+
+func TestOTLPExport(t *testing.T) {
+	c := newTestCollector(t, http.StatusOK)
+	l := newOTLPLogger(t, c, 0)
+
+	l.Info("exported")
+	l.Debug("exported too")
+	l.Flush()
+
+	if got := c.received(); !equalStrings(got, []string{"exported", "exported too"}) {
+		t.Fatalf("collector received %q", got)
+	}
+}
+
+func TestOTLPCloseShutsDownExporter(t *testing.T) {
+	c := newTestCollector(t, http.StatusOK)
+	l := newOTLPLogger(t, c, 0)
+	exporter := l.OTLPExporter()
+	if exporter == nil {
+		t.Fatal("no exporter")
+	}
+
+	l.Info("queued")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the queued record is exported and the background exports stopped
+	if got := c.received(); !equalStrings(got, []string{"queued"}) {
+		t.Fatalf("collector received %q", got)
+	}
+	select {
+	case <-exporter.done:
+	default:
+		t.Fatal("exporter not shut down")
+	}
+}
+
+func TestOTLPSyncDeadline(t *testing.T) {
+	shortenOTLPSyncTimeout(t)
+
+	// the retries of an unavailable collector take minutes
+	c := newTestCollector(t, http.StatusServiceUnavailable)
+	l := newOTLPLogger(t, c, 100)
+
+	l.Info("dropped")
+
+	start := time.Now()
+	l.Flush()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("flush took %v, want the sync timeout", elapsed)
+	}
+	if dropped := l.OTLPExporter().Dropped(); dropped != 1 {
+		t.Fatalf("%d dropped records, want 1", dropped)
+	}
+}
+
+func TestOTLPCloseDeadline(t *testing.T) {
+	shortenOTLPSyncTimeout(t)
+
+	c := newTestCollector(t, http.StatusOK)
+	c.block = make(chan struct{})
+	defer close(c.block)
+	l := newOTLPLogger(t, c, 100)
+
+	// a background export blocked by the collector
+	for i := 0; i < 100; i++ {
+		l.Infow("blocked", "i", i)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	_ = l.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("close took %v, want the sync timeout", elapsed)
+	}
+}
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opentracing/opentracing-go"
+	tag "github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+// ErrorHandler receives the panics recovered by Recover and Go.
+// shutdown.ErrorHandler implements it.
+type ErrorHandler interface {
+	OnError(error)
+}
+
+type recoverOptions struct {
+	repanic      bool
+	errorHandler ErrorHandler
+}
+
+// RecoverOption optional parameters for Recover and Go
+type RecoverOption func(*recoverOptions)
+
+// WithRepanic panics again with the recovered value after logging it
+func WithRepanic() RecoverOption {
+	return func(o *recoverOptions) {
+		o.repanic = true
+	}
+}
+
+// WithErrorHandler reports the recovered panic as an error to h
+func WithErrorHandler(h ErrorHandler) RecoverOption {
+	return func(o *recoverOptions) {
+		o.errorHandler = h
+	}
+}
+
+// Recover recovers from a panic and logs it with the stack at error level,
+// using the context logger of ctx. It must be deferred directly:
+//
+//	defer log.Recover(ctx)
+func Recover(ctx context.Context, opts ...RecoverOption) {
+	if r := recover(); r != nil {
+		handlePanic(ctx, r, opts)
+	}
+}
+
+// Go runs fn in a new goroutine, panics of fn are handled as by Recover.
+func Go(ctx context.Context, fn func(ctx context.Context), opts ...RecoverOption) {
+	go func() {
+		defer Recover(ctx, opts...)
+
+		fn(ctx)
+	}()
+}
+
+func handlePanic(ctx context.Context, r interface{}, opts []RecoverOption) {
+	o := &recoverOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// the stack trace is recorded on the entry rather than as a field, so
+	// that it is the only one
+	logger := ContextLogger(ctx)
+	if zl, ok := logger.(*zapLogger); ok {
+		zl.errorWithStack("recovered from panic", zap.Any("panic", r))
+	} else {
+		logger.Error("recovered from panic", zap.Any("panic", r))
+	}
+	// loggers of other implementations don't know about spans
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		tag.Error.Set(span, true)
+	}
+
+	if o.errorHandler != nil {
+		err, ok := r.(error)
+		if ok {
+			err = fmt.Errorf("recovered from panic: %w", err)
+		} else {
+			err = fmt.Errorf("recovered from panic: %v", r)
+		}
+		o.errorHandler.OnError(err)
+	}
+
+	if o.repanic {
+		panic(r)
+	}
+}
+
+// ContextLogger returns the logger of ctx stored by WithContext or the
+// default one, with the common fields and the span of ctx. It is the
+// logger of the middlewares and wrappers given a request context.
+func ContextLogger(ctx context.Context) Logger {
+	if ctx == nil {
+		return std
+	}
+
+	logger, ok := ctx.Value(logContextKey).(Logger)
+	if !ok {
+		logger = std
+	}
+
+	logger = logger.C(ctx)
+	if zl, ok := logger.(*zapLogger); ok {
+		return zl.J(ctx)
+	}
+
+	return logger
+}
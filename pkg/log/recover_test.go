@@ -0,0 +1,147 @@
+package log_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "git.enn-edge.com/device_manage/public/log.git"
+)
+
+// newLogger creates a logger writing the JSON entries to a file of the
+// test directory, returned with a function reading the entries
+func newLogger(t *testing.T, configure func(*log.Options)) (log.Logger, func() []map[string]interface{}) {
+	t.Helper()
+
+	opts := log.NewOptions()
+	opts.Format = "json"
+	opts.OutputPaths = []string{filepath.Join(t.TempDir(), "test.log")}
+	if configure != nil {
+		configure(opts)
+	}
+	l := log.New(opts)
+
+	return l, func() []map[string]interface{} {
+		t.Helper()
+
+		l.Flush()
+		data, err := os.ReadFile(opts.OutputPaths[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var entries []map[string]interface{}
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("invalid entry %q: %v", scanner.Text(), err)
+			}
+			if strings.Count(scanner.Text(), `"stacktrace"`) > 1 {
+				t.Fatalf("entry with several stack traces: %s", scanner.Text())
+			}
+			entries = append(entries, entry)
+		}
+
+		return entries
+	}
+}
+
+type errorHandlerFunc func(error)
+
+func (f errorHandlerFunc) OnError(err error) {
+	f(err)
+}
+
+func panicking() {
+	panic(errors.New("boom"))
+}
+
+func TestRecover(t *testing.T) {
+	l, entries := newLogger(t, nil)
+	ctx := l.WithContext(context.WithValue(context.Background(), "requestID", "r1"))
+
+	func() {
+		defer log.Recover(ctx)
+
+		panicking()
+	}()
+
+	got := entries()
+	if len(got) != 1 {
+		t.Fatalf("%d entries, want 1", len(got))
+	}
+	if got[0]["message"] != "recovered from panic" || got[0]["panic"] != "boom" || got[0]["requestID"] != "r1" {
+		t.Fatalf("recovered entry %v", got[0])
+	}
+
+	stack, _ := got[0]["stacktrace"].(string)
+	if !strings.HasPrefix(stack, "git.enn-edge.com/device_manage/public/log%2egit_test.panicking\n") {
+		t.Fatalf("stack trace does not start at the panic:\n%s", stack)
+	}
+}
+
+func TestRecoverDisableStacktrace(t *testing.T) {
+	l, entries := newLogger(t, func(opts *log.Options) {
+		opts.DisableStacktrace = true
+	})
+
+	func() {
+		defer log.Recover(l.WithContext(context.Background()))
+
+		panicking()
+	}()
+
+	got := entries()
+	if len(got) != 1 || got[0]["stacktrace"] != nil {
+		t.Fatalf("entries %v, want one without stack trace", got)
+	}
+}
+
+func TestGoErrorHandler(t *testing.T) {
+	l, entries := newLogger(t, nil)
+
+	errs := make(chan error, 1)
+	log.Go(l.WithContext(context.Background()), func(context.Context) {
+		panicking()
+	}, log.WithErrorHandler(errorHandlerFunc(func(err error) { errs <- err })))
+
+	err := <-errs
+	if err.Error() != "recovered from panic: boom" {
+		t.Fatalf("error %q", err)
+	}
+	if got := entries(); len(got) != 1 || got[0]["panic"] != "boom" {
+		t.Fatalf("entries %v, want the recovered panic", got)
+	}
+
+	// values other than errors are formatted
+	log.Go(l.WithContext(context.Background()), func(context.Context) {
+		panic(42)
+	}, log.WithErrorHandler(errorHandlerFunc(func(err error) { errs <- err })))
+
+	if err := <-errs; err.Error() != "recovered from panic: 42" {
+		t.Fatalf("error %q", err)
+	}
+}
+
+func TestRecoverRepanic(t *testing.T) {
+	l, entries := newLogger(t, nil)
+
+	defer func() {
+		if r := recover(); r == nil || r.(error).Error() != "boom" {
+			t.Fatalf("repanicked with %v, want boom", r)
+		}
+		if got := entries(); len(got) != 1 {
+			t.Fatalf("%d entries, want 1 before panicking again", len(got))
+		}
+	}()
+
+	defer log.Recover(l.WithContext(context.Background()), log.WithRepanic())
+
+	panicking()
+}
@@ -0,0 +1,226 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ringBuffer keeps the last encoded log entries in memory
+type ringBuffer struct {
+	mu      sync.Mutex
+	entries [][]byte
+	next    int
+	full    bool
+	// crashFile receives the entries when an entry of crashLevel or above
+	// is written
+	crashFile  string
+	crashLevel Level
+}
+
+// newRingBuffer creates a ring buffer of size entries dumped to crashFile
+// on the panic and fatal entries, and on the dpanic ones in development,
+// where they panic too
+func newRingBuffer(size int, crashFile string, development bool) *ringBuffer {
+	crashLevel := PanicLevel
+	if development {
+		crashLevel = DPanicLevel
+	}
+
+	return &ringBuffer{
+		entries:    make([][]byte, size),
+		crashFile:  crashFile,
+		crashLevel: crashLevel,
+	}
+}
+
+func (r *ringBuffer) add(entry []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = entry
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// snapshot returns the entries from the oldest to the newest
+func (r *ringBuffer) snapshot() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([][]byte(nil), r.entries[:r.next]...)
+	}
+
+	entries := make([][]byte, 0, len(r.entries))
+	entries = append(entries, r.entries[r.next:]...)
+
+	return append(entries, r.entries[:r.next]...)
+}
+
+// dump returns the entries as a JSON array
+func (r *ringBuffer) dump() []byte {
+	entries := r.snapshot()
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, entry := range entries {
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+		buf.Write(entry)
+	}
+	buf.WriteString("]\n")
+
+	return buf.Bytes()
+}
+
+func (r *ringBuffer) dumpToCrashFile() error {
+	if r.crashFile == "" {
+		return nil
+	}
+
+	return os.WriteFile(r.crashFile, r.dump(), 0o600)
+}
+
+// ringCore is a zapcore.Core recording entries into a ringBuffer. It is
+// teed with the sinks core and usually enabled at a lower level than it.
+type ringCore struct {
+	zapcore.LevelEnabler
+	enc  zapcore.Encoder
+	ring *ringBuffer
+}
+
+func newRingCore(ring *ringBuffer, enc zapcore.Encoder, enab zapcore.LevelEnabler) *ringCore {
+	return &ringCore{
+		LevelEnabler: enab,
+		enc:          enc,
+		ring:         ring,
+	}
+}
+
+func (c *ringCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &ringCore{
+		LevelEnabler: c.LevelEnabler,
+		enc:          c.enc.Clone(),
+		ring:         c.ring,
+	}
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+
+	return clone
+}
+
+func (c *ringCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *ringCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	entry := bytes.TrimRight(buf.Bytes(), "\n")
+	c.ring.add(append([]byte(nil), entry...))
+	buf.Free()
+
+	if ent.Level >= c.ring.crashLevel {
+		return c.ring.dumpToCrashFile()
+	}
+
+	return nil
+}
+
+func (c *ringCore) Sync() error {
+	return nil
+}
+
+// ringTee tees the logger core with a ringCore, unlike zapcore.NewTee the
+// logger core can still be elevated.
+type ringTee struct {
+	zapcore.Core
+	ring zapcore.Core
+}
+
+func newRingTee(core zapcore.Core, ring *ringCore) *ringTee {
+	return &ringTee{
+		Core: core,
+		ring: ring,
+	}
+}
+
+func (c *ringTee) Enabled(lvl zapcore.Level) bool {
+	return c.Core.Enabled(lvl) || c.ring.Enabled(lvl)
+}
+
+func (c *ringTee) With(fields []zapcore.Field) zapcore.Core {
+	return &ringTee{
+		Core: c.Core.With(fields),
+		ring: c.ring.With(fields),
+	}
+}
+
+func (c *ringTee) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.ring.Check(ent, c.Core.Check(ent, ce))
+}
+
+func (c *ringTee) elevate() zapcore.Core {
+	return &ringTee{
+		Core: elevateCore(c.Core),
+		ring: c.ring,
+	}
+}
+
+// RecentEntries returns the entries kept in the ring buffer of the default
+// logger as JSON, from the oldest to the newest. It is nil if the ring
+// buffer is disabled.
+func RecentEntries() []json.RawMessage {
+	return std.RecentEntries()
+}
+
+func (l *zapLogger) RecentEntries() []json.RawMessage {
+	if l.ring == nil {
+		return nil
+	}
+
+	entries := l.ring.snapshot()
+	raws := make([]json.RawMessage, len(entries))
+	for i, entry := range entries {
+		raws[i] = entry
+	}
+
+	return raws
+}
+
+// RecentEntriesHandler serves the entries kept in the ring buffer of the
+// default logger as a JSON array.
+func RecentEntriesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		std.RecentEntriesHandler().ServeHTTP(w, r)
+	})
+}
+
+func (l *zapLogger) RecentEntriesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.ring == nil {
+			http.Error(w, "log ring buffer is disabled", http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(l.ring.dump())
+	})
+}
@@ -0,0 +1,131 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func messages(t *testing.T, raws []json.RawMessage) []string {
+	t.Helper()
+
+	msgs := make([]string, len(raws))
+	for i, raw := range raws {
+		var entry struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			t.Fatalf("invalid entry %s: %v", raw, err)
+		}
+		msgs[i] = entry.Message
+	}
+
+	return msgs
+}
+
+func TestRingBufferEviction(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.Level = "info"
+	opts.RingBufferSize = 3
+	l, entries := newTestLogger(t, opts)
+
+	if got := l.RecentEntries(); len(got) != 0 {
+		t.Fatalf("%d recent entries, want none", len(got))
+	}
+
+	// the debug entries are kept below the level of the logger
+	l.Debug("1")
+	l.Info("2")
+	l.Debug("3")
+	l.Info("4")
+	l.Debug("5")
+
+	got := messages(t, l.RecentEntries())
+	if len(got) != 3 || got[0] != "3" || got[1] != "4" || got[2] != "5" {
+		t.Fatalf("recent entries %v, want [3 4 5]", got)
+	}
+	if n := len(entries()); n != 2 {
+		t.Fatalf("%d entries written, want 2", n)
+	}
+
+	rec := httptest.NewRecorder()
+	l.RecentEntriesHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var served []json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("invalid response %s: %v", rec.Body, err)
+	}
+	if got := messages(t, served); len(got) != 3 || got[2] != "5" {
+		t.Fatalf("served entries %v, want [3 4 5]", got)
+	}
+}
+
+func TestRingBufferDisabled(t *testing.T) {
+	l, _ := newTestLogger(t, nil)
+
+	if l.RecentEntries() != nil {
+		t.Fatal("recent entries without ring buffer")
+	}
+
+	rec := httptest.NewRecorder()
+	l.RecentEntriesHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", rec.Code)
+	}
+}
+
+func TestRingBufferCrashDump(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.RingBufferSize = 10
+	opts.CrashFile = filepath.Join(t.TempDir(), "crash.json")
+	l, _ := newTestLogger(t, opts)
+
+	l.Debug("before")
+	func() {
+		defer func() { _ = recover() }()
+
+		l.Panic("crash")
+	}()
+
+	data, err := os.ReadFile(opts.CrashFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dumped []json.RawMessage
+	if err := json.Unmarshal(data, &dumped); err != nil {
+		t.Fatalf("invalid crash file %s: %v", data, err)
+	}
+	if got := messages(t, dumped); len(got) != 2 || got[0] != "before" || got[1] != "crash" {
+		t.Fatalf("dumped entries %v, want [before crash]", got)
+	}
+}
+
+func TestRingBufferCrashDumpDPanic(t *testing.T) {
+	for _, development := range []bool{false, true} {
+		opts := newTestOptions(t)
+		opts.RingBufferSize = 10
+		opts.CrashFile = filepath.Join(t.TempDir(), "crash.json")
+		opts.Development = development
+		l, _ := newTestLogger(t, opts)
+
+		func() {
+			defer func() { _ = recover() }()
+
+			l.DPanic("crash")
+		}()
+
+		// the dpanic entries panic in development only, where they are
+		// dumped like the panic ones
+		_, err := os.Stat(opts.CrashFile)
+		if development && err != nil {
+			t.Fatalf("dpanic entry not dumped in development: %v", err)
+		}
+		if !development && !os.IsNotExist(err) {
+			t.Fatalf("dpanic entry dumped out of development: %v", err)
+		}
+	}
+}
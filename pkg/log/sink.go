@@ -0,0 +1,418 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Failure policies of the output sinks, set per output path by SinkPolicies
+const (
+	// SinkPolicyNone reports the failed writes to the error outputs
+	SinkPolicyNone = "none"
+	// SinkPolicyDrop drops and counts the entries that failed to be written
+	SinkPolicyDrop = "drop"
+	// SinkPolicyRetry buffers the entries and retries them with backoff
+	SinkPolicyRetry = "retry"
+	// SinkPolicyFailover writes the entries to a secondary sink, FAILOVER:PATH
+	SinkPolicyFailover = "failover"
+)
+
+// sinkRetryBackoff is the first backoff between the retries of a sink
+const sinkRetryBackoff = 100 * time.Millisecond
+
+// errSinkClosed is returned by the writes to a closed sink
+var errSinkClosed = errors.New("write to a closed log sink")
+
+// SinkStatus is the health of an output sink.
+type SinkStatus struct {
+	Path   string `json:"path"`
+	Policy string `json:"policy"`
+	// Healthy reports whether the last write to the sink succeeded
+	Healthy bool `json:"healthy"`
+	// Failures counts the failed writes, retries included
+	Failures uint64 `json:"failures"`
+	// Dropped counts the entries lost
+	Dropped uint64 `json:"dropped"`
+	// FailedOver counts the entries written to the secondary sink
+	FailedOver uint64 `json:"failed_over"`
+	// Pending is the number of entries waiting to be retried
+	Pending     int        `json:"pending"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+}
+
+// parseSinkPolicy parses a policy of SinkPolicies into its name and the
+// path of the secondary sink
+func parseSinkPolicy(s string) (policy, fallback string, err error) {
+	policy, fallback, _ = strings.Cut(s, ":")
+	policy = strings.ToLower(policy)
+
+	switch policy {
+	case SinkPolicyNone, SinkPolicyDrop, SinkPolicyRetry:
+		if fallback != "" {
+			return "", "", fmt.Errorf("sink policy %q takes no path: %q", policy, s)
+		}
+	case SinkPolicyFailover:
+		if fallback == "" {
+			return "", "", fmt.Errorf("sink policy %q needs a path: %q", policy, s)
+		}
+	default:
+		return "", "", fmt.Errorf("not a valid sink policy: %q", s)
+	}
+
+	return policy, fallback, nil
+}
+
+// guardedSink applies the failure policy to the writes of an output sink
+// and keeps its health.
+type guardedSink struct {
+	ws         zapcore.WriteSyncer
+	fallback   zapcore.WriteSyncer
+	maxPending int
+	maxBackoff time.Duration
+	// closers close ws and fallback
+	closers []func()
+	// done stops the retries once closed
+	done chan struct{}
+
+	mu       sync.Mutex
+	status   SinkStatus
+	pending  [][]byte
+	retrying bool
+	closed   bool
+}
+
+// openSinks opens the output paths, each guarded by its failure policy
+func openSinks(opts *Options) ([]*guardedSink, error) {
+	sinks := make([]*guardedSink, 0, len(opts.OutputPaths))
+	for _, path := range opts.OutputPaths {
+		s, err := openSink(opts, path)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, s)
+	}
+
+	return sinks, nil
+}
+
+// openSink opens an output path guarded by its failure policy
+func openSink(opts *Options, path string) (*guardedSink, error) {
+	return openPolicySink(opts, path, opts.SinkPolicies[path])
+}
+
+// openPolicySink opens an output path guarded by a policy of SinkPolicies,
+// empty means none
+func openPolicySink(opts *Options, path, sinkPolicy string) (*guardedSink, error) {
+	maxPending, maxBackoff := opts.SinkRetryBuffer, opts.SinkRetryMaxBackoff
+	if maxPending < 1 {
+		maxPending = 1
+	}
+	if maxBackoff < sinkRetryBackoff {
+		maxBackoff = sinkRetryBackoff
+	}
+
+	policy, fallbackPath := SinkPolicyNone, ""
+	if sinkPolicy != "" {
+		var err error
+		if policy, fallbackPath, err = parseSinkPolicy(sinkPolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	ws, closeSink, err := zap.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newGuardedSink(path, policy, ws, maxPending, maxBackoff)
+	s.closers = append(s.closers, closeSink)
+
+	if fallbackPath != "" {
+		fallback, closeFallback, err := zap.Open(fallbackPath)
+		if err != nil {
+			closeSink()
+
+			return nil, err
+		}
+
+		s.fallback = fallback
+		s.closers = append(s.closers, closeFallback)
+	}
+
+	return s, nil
+}
+
+func newGuardedSink(path, policy string, ws zapcore.WriteSyncer, maxPending int, maxBackoff time.Duration) *guardedSink {
+	return &guardedSink{
+		ws:         ws,
+		maxPending: maxPending,
+		maxBackoff: maxBackoff,
+		done:       make(chan struct{}),
+		status: SinkStatus{
+			Path:    path,
+			Policy:  policy,
+			Healthy: true,
+		},
+	}
+}
+
+// writeSyncer combines the sinks into the write syncer of a core
+func writeSyncer(sinks []*guardedSink) zapcore.WriteSyncer {
+	wss := make([]zapcore.WriteSyncer, len(sinks))
+	for i, s := range sinks {
+		wss[i] = s
+	}
+
+	return zapcore.NewMultiWriteSyncer(wss...)
+}
+
+func (s *guardedSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		s.status.Dropped++
+
+		return 0, errSinkClosed
+	}
+
+	// entries queue behind the pending ones to keep their order
+	if len(s.pending) > 0 {
+		s.enqueue(p)
+
+		return len(p), nil
+	}
+
+	err := write(s.ws, p)
+	if err == nil {
+		s.status.Healthy = true
+
+		return len(p), nil
+	}
+	s.fail(err)
+
+	switch s.status.Policy {
+	case SinkPolicyDrop:
+		s.status.Dropped++
+
+		return len(p), nil
+	case SinkPolicyRetry:
+		s.enqueue(p)
+
+		return len(p), nil
+	case SinkPolicyFailover:
+		if err := write(s.fallback, p); err != nil {
+			s.status.Dropped++
+
+			return 0, fmt.Errorf("write to %s and its secondary sink: %w", s.status.Path, err)
+		}
+		s.status.FailedOver++
+
+		return len(p), nil
+	}
+
+	s.status.Dropped++
+
+	return 0, err
+}
+
+// Sync retries the pending entries once and syncs the sink
+func (s *guardedSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	return s.sync()
+}
+
+// sync flushes and syncs the sink, the caller holds the lock
+func (s *guardedSink) sync() error {
+	s.flush()
+
+	err := s.ws.Sync()
+	if s.fallback != nil {
+		if ferr := s.fallback.Sync(); err == nil {
+			err = ferr
+		}
+	}
+
+	return err
+}
+
+func write(ws zapcore.WriteSyncer, p []byte) error {
+	n, err := ws.Write(p)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+
+	return err
+}
+
+// fail records a failed write, the caller holds the lock
+func (s *guardedSink) fail(err error) {
+	now := time.Now()
+	s.status.Healthy = false
+	s.status.Failures++
+	s.status.LastError = err.Error()
+	s.status.LastFailure = &now
+}
+
+// enqueue copies p to the pending entries, dropping the oldest entry if
+// they are full, and starts retrying them. The caller holds the lock.
+func (s *guardedSink) enqueue(p []byte) {
+	if len(s.pending) >= s.maxPending {
+		s.pending[0] = nil
+		s.pending = s.pending[1:]
+		s.status.Dropped++
+	}
+	// the buffers of the entries are reused by zap
+	s.pending = append(s.pending, append([]byte(nil), p...))
+
+	if !s.retrying {
+		s.retrying = true
+		go s.retry()
+	}
+}
+
+// flush writes the pending entries until one fails, the caller holds the
+// lock
+func (s *guardedSink) flush() {
+	for len(s.pending) > 0 {
+		if err := write(s.ws, s.pending[0]); err != nil {
+			s.fail(err)
+
+			return
+		}
+		s.pending[0] = nil
+		s.pending = s.pending[1:]
+	}
+	s.status.Healthy = true
+}
+
+// Close retries the pending entries a last time, drops those still
+// failing, stops the retries and closes the sink and its secondary sink.
+func (s *guardedSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	err := s.sync()
+	s.status.Dropped += uint64(len(s.pending))
+	s.pending = nil
+	s.closed = true
+	close(s.done)
+
+	for _, closeSink := range s.closers {
+		closeSink()
+	}
+
+	return err
+}
+
+// retry flushes the pending entries with an exponential backoff, it returns
+// once they are all written or the sink is closed
+func (s *guardedSink) retry() {
+	backoff := sinkRetryBackoff
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-s.done:
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+
+			return
+		}
+		s.flush()
+		if len(s.pending) == 0 {
+			s.retrying = false
+			s.mu.Unlock()
+
+			return
+		}
+		s.mu.Unlock()
+
+		if backoff *= 2; backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+		timer.Reset(backoff)
+	}
+}
+
+func (s *guardedSink) snapshot() SinkStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status
+	status.Pending = len(s.pending)
+
+	return status
+}
+
+// SinkStatuses returns the health of the output sinks of the default
+// logger.
+func SinkStatuses() []SinkStatus {
+	return std.SinkStatuses()
+}
+
+func (l *zapLogger) SinkStatuses() []SinkStatus {
+	statuses := make([]SinkStatus, len(l.sinks))
+	for i, s := range l.sinks {
+		statuses[i] = s.snapshot()
+	}
+
+	// the sinks of the tenants are those currently open
+	if l.tenants != nil {
+		statuses = append(statuses, l.tenants.statuses()...)
+	}
+
+	return statuses
+}
+
+// SinkStatusHandler serves the health of the output sinks of the default
+// logger as JSON, with status 503 if one of them is unhealthy, so that it
+// can back readiness probes.
+func SinkStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		std.SinkStatusHandler().ServeHTTP(w, r)
+	})
+}
+
+func (l *zapLogger) SinkStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statuses := l.SinkStatuses()
+		code := http.StatusOK
+		for _, status := range statuses {
+			if !status.Healthy {
+				code = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(statuses)
+	})
+}
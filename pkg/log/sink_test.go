@@ -0,0 +1,244 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSink is a write syncer failing while failing is set
+type testSink struct {
+	mu      sync.Mutex
+	failing bool
+	writes  []string
+	closed  bool
+}
+
+func (s *testSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failing {
+		return 0, errors.New("sink unavailable")
+	}
+	s.writes = append(s.writes, string(p))
+
+	return len(p), nil
+}
+
+func (s *testSink) Sync() error {
+	return nil
+}
+
+func (s *testSink) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failing = failing
+}
+
+func (s *testSink) written() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.writes...)
+}
+
+// newTestSink guards ws by policy, retrying every 10ms at most
+func newTestSink(policy string, ws *testSink, maxPending int) *guardedSink {
+	s := newGuardedSink("test", policy, ws, maxPending, 10*time.Millisecond)
+	s.closers = append(s.closers, func() { ws.closed = true })
+
+	return s
+}
+
+// waitFor polls cond until it holds or a second passed
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met after 1s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSinkPolicyNone(t *testing.T) {
+	ws := &testSink{failing: true}
+	s := newTestSink(SinkPolicyNone, ws, 1)
+
+	if _, err := s.Write([]byte("a\n")); err == nil {
+		t.Fatal("failed write without error")
+	}
+	if status := s.snapshot(); status.Healthy || status.Failures != 1 || status.Dropped != 1 || status.LastError == "" {
+		t.Fatalf("status %+v", status)
+	}
+}
+
+func TestSinkPolicyDrop(t *testing.T) {
+	ws := &testSink{failing: true}
+	s := newTestSink(SinkPolicyDrop, ws, 1)
+
+	if n, err := s.Write([]byte("a\n")); err != nil || n != 2 {
+		t.Fatalf("dropped write returned %d, %v", n, err)
+	}
+	if status := s.snapshot(); status.Healthy || status.Dropped != 1 {
+		t.Fatalf("status %+v", status)
+	}
+
+	ws.setFailing(false)
+	if _, err := s.Write([]byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+	if status := s.snapshot(); !status.Healthy || status.Dropped != 1 {
+		t.Fatalf("status %+v after recovery", status)
+	}
+	if got := ws.written(); !equalStrings(got, []string{"b\n"}) {
+		t.Fatalf("written %q", got)
+	}
+}
+
+func TestSinkPolicyRetry(t *testing.T) {
+	ws := &testSink{failing: true}
+	s := newTestSink(SinkPolicyRetry, ws, 2)
+	defer s.Close()
+
+	for _, p := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := s.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the oldest entry is dropped beyond 2 pending ones
+	if status := s.snapshot(); status.Healthy || status.Pending != 2 || status.Dropped != 1 {
+		t.Fatalf("status %+v", status)
+	}
+
+	ws.setFailing(false)
+	waitFor(t, func() bool { return s.snapshot().Pending == 0 })
+
+	if got := ws.written(); !equalStrings(got, []string{"b\n", "c\n"}) {
+		t.Fatalf("retried %q, want the pending entries in order", got)
+	}
+	if status := s.snapshot(); !status.Healthy {
+		t.Fatalf("status %+v after the retries", status)
+	}
+}
+
+func TestSinkPolicyFailover(t *testing.T) {
+	ws, fallback := &testSink{failing: true}, &testSink{}
+	s := newTestSink(SinkPolicyFailover, ws, 1)
+	s.fallback = fallback
+
+	if _, err := s.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := fallback.written(); !equalStrings(got, []string{"a\n"}) {
+		t.Fatalf("secondary sink written %q", got)
+	}
+	if status := s.snapshot(); status.FailedOver != 1 || status.Dropped != 0 {
+		t.Fatalf("status %+v", status)
+	}
+
+	fallback.setFailing(true)
+	if _, err := s.Write([]byte("b\n")); err == nil {
+		t.Fatal("write without error while both sinks fail")
+	}
+	if status := s.snapshot(); status.Dropped != 1 {
+		t.Fatalf("status %+v", status)
+	}
+}
+
+func TestSinkCloseStopsRetries(t *testing.T) {
+	ws := &testSink{failing: true}
+	s := newTestSink(SinkPolicyRetry, ws, 10)
+
+	if _, err := s.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := s.snapshot(); status.Pending != 0 || status.Dropped != 1 {
+		t.Fatalf("status %+v, want the pending entry dropped", status)
+	}
+	if !ws.closed {
+		t.Fatal("sink not closed")
+	}
+
+	// the retries are stopped
+	ws.setFailing(false)
+	time.Sleep(50 * time.Millisecond)
+	if got := ws.written(); len(got) != 0 {
+		t.Fatalf("written %q after close", got)
+	}
+
+	if _, err := s.Write([]byte("b\n")); !errors.Is(err, errSinkClosed) {
+		t.Fatalf("write after close returned %v", err)
+	}
+}
+
+func TestSinkStatusHandler(t *testing.T) {
+	l, _ := newTestLogger(t, nil)
+
+	serve := func() (int, []SinkStatus) {
+		rec := httptest.NewRecorder()
+		l.SinkStatusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		var statuses []SinkStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &statuses); err != nil {
+			t.Fatal(err)
+		}
+
+		return rec.Code, statuses
+	}
+
+	if code, statuses := serve(); code != http.StatusOK || len(statuses) != 1 || !statuses[0].Healthy {
+		t.Fatalf("healthy sinks served %d %+v", code, statuses)
+	}
+
+	failing := newTestSink(SinkPolicyDrop, &testSink{failing: true}, 1)
+	_, _ = failing.Write([]byte("a\n"))
+	l.sinks = append(l.sinks, failing)
+
+	code, statuses := serve()
+	if code != http.StatusServiceUnavailable || len(statuses) != 2 || statuses[1].Healthy || statuses[1].Dropped != 1 {
+		t.Fatalf("unhealthy sink served %d %+v", code, statuses)
+	}
+}
+
+func TestResetDefaultKeepsDerived(t *testing.T) {
+	previous := std
+	defer func() { std = previous }()
+
+	opts := newTestOptions(t)
+	std = New(opts)
+	derived := WithName("pkg")
+
+	old := ResetDefault(newTestOptions(t))
+	defer Close()
+
+	// the loggers derived before the reset still write to the sinks of the
+	// previous default logger, until it is closed
+	derived.Info("derived")
+	Info("reset")
+	derived.Flush()
+	if got := entryMessages(readEntries(t, opts.OutputPaths[0])); !equalStrings(got, []string{"derived"}) {
+		t.Fatalf("previous default logger has %v, want the entry of the derived logger", got)
+	}
+
+	if err := old.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range old.sinks {
+		if !s.closed {
+			t.Fatalf("sink %s of the previous default logger not closed", s.status.Path)
+		}
+	}
+}
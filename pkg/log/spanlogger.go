@@ -15,6 +15,9 @@ func (l *zapLogger) logToSpan(level string, msg string, fields ...Field) {
 	for _, field := range fields {
 		field.AddTo(&fa)
 	}
+	if l.limits != nil {
+		fa = l.limits.spanFields(fa)
+	}
 	l.span.LogFields(fa...)
 }
 
@@ -0,0 +1,121 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelPlaceholder is replaced by the level names in LevelOutputPath, and
+// by error in ErrorOutputPaths
+const LevelPlaceholder = "{level}"
+
+// levelOutput is a split file, holding the entries from its level up to
+// the level of the next split file
+type levelOutput struct {
+	path string
+	enab levelRangeEnabler
+}
+
+// levelRangeEnabler enables the levels ranked from min up to, excluding,
+// next. The last range has no upper bound.
+type levelRangeEnabler struct {
+	min  Level
+	next Level
+	last bool
+}
+
+func (e levelRangeEnabler) Enabled(l zapcore.Level) bool {
+	if levelRank(l) < levelRank(e.min) {
+		return false
+	}
+
+	return e.last || levelRank(l) < levelRank(e.next)
+}
+
+// levelOutputs expands LevelOutputPath into the split files of
+// LevelOutputLevels, ordered by level
+func (o *Options) levelOutputs() ([]levelOutput, error) {
+	if o.LevelOutputPath == "" {
+		return nil, nil
+	}
+
+	if !strings.Contains(o.LevelOutputPath, LevelPlaceholder) {
+		return nil, fmt.Errorf("level output path without %s: %q", LevelPlaceholder, o.LevelOutputPath)
+	}
+
+	if len(o.LevelOutputLevels) == 0 {
+		return nil, fmt.Errorf("no levels for the level output path %q", o.LevelOutputPath)
+	}
+
+	levels := make([]Level, 0, len(o.LevelOutputLevels))
+	for _, text := range o.LevelOutputLevels {
+		l, err := ParseLevel(text)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, seen := range levels {
+			if seen == l {
+				return nil, fmt.Errorf("duplicated level output level: %q", text)
+			}
+		}
+
+		levels = append(levels, l)
+	}
+
+	sort.Slice(levels, func(i, j int) bool {
+		return levelRank(levels[i]) < levelRank(levels[j])
+	})
+
+	outputs := make([]levelOutput, len(levels))
+	for i, l := range levels {
+		outputs[i] = levelOutput{
+			path: strings.ReplaceAll(o.LevelOutputPath, LevelPlaceholder, LevelString(l)),
+			enab: levelRangeEnabler{min: l, last: i == len(levels)-1},
+		}
+		if i < len(levels)-1 {
+			outputs[i].enab.next = levels[i+1]
+		}
+	}
+
+	return outputs, nil
+}
+
+// errorOutputPaths returns ErrorOutputPaths, LevelPlaceholder replaced by
+// error so that the errors of the logger go next to the error entries
+func (o *Options) errorOutputPaths() []string {
+	paths := make([]string, len(o.ErrorOutputPaths))
+	for i, path := range o.ErrorOutputPaths {
+		paths[i] = strings.ReplaceAll(path, LevelPlaceholder, LevelString(ErrorLevel))
+	}
+
+	return paths
+}
+
+// newSplitCore creates the core writing the split files, each file gets
+// the entries of its level range. The files are opened as the output
+// paths: they take the sink policies of their expanded paths, and the
+// sinks registered with zap, such as rotating ones, through URL schemes.
+func newSplitCore(opts *Options, enc zapcore.Encoder) (zapcore.Core, []*guardedSink, error) {
+	outputs, err := opts.levelOutputs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cores := make([]zapcore.Core, 0, len(outputs))
+	sinks := make([]*guardedSink, 0, len(outputs))
+	for _, output := range outputs {
+		s, err := openSink(opts, output.path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cores = append(cores, zapcore.NewCore(enc, s, output.enab))
+		sinks = append(sinks, s)
+	}
+
+	return zapcore.NewTee(cores...), sinks, nil
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitByLevel(t *testing.T) {
+	opts := newTestOptions(t)
+	dir := filepath.Dir(opts.OutputPaths[0])
+	opts.LevelOutputPath = filepath.Join(dir, LevelPlaceholder+".log")
+	opts.LevelOutputLevels = []string{"error", "info", "warn"}
+	opts.ErrorOutputPaths = []string{filepath.Join(dir, "internal-"+LevelPlaceholder+".log")}
+
+	l, entries := newTestLogger(t, opts)
+	l.Trace("trace")
+	l.Debug("debug")
+	l.Info("info")
+	l.Notice("notice")
+	l.Warn("warn")
+	l.Error("error")
+	l.Flush()
+
+	// the main output has every entry
+	if got := entryMessages(entries()); !equalStrings(got, []string{"trace", "debug", "info", "notice", "warn", "error"}) {
+		t.Fatalf("output has %v, want every entry", got)
+	}
+
+	// each file holds its level up to the next one, notice ranks between
+	// info and warn and entries below the lowest level are not split
+	want := map[string][]string{
+		"info":  {"info", "notice"},
+		"warn":  {"warn"},
+		"error": {"error"},
+	}
+	for level, messages := range want {
+		path := strings.ReplaceAll(opts.LevelOutputPath, LevelPlaceholder, level)
+		if got := entryMessages(readEntries(t, path)); !equalStrings(got, messages) {
+			t.Errorf("%s has %v, want %v", path, got, messages)
+		}
+	}
+
+	if got := opts.errorOutputPaths(); got[0] != filepath.Join(dir, "internal-error.log") {
+		t.Errorf("error output path %q, want the placeholder replaced by error", got[0])
+	}
+}
+
+func TestLevelOutputsInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		path   string
+		levels []string
+	}{
+		{"no placeholder", "/var/log/svc.log", []string{"info"}},
+		{"no levels", "/var/log/{level}.log", nil},
+		{"unknown level", "/var/log/{level}.log", []string{"verbose"}},
+		{"duplicated level", "/var/log/{level}.log", []string{"warn", "WARN"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := NewOptions()
+			opts.LevelOutputPath = tt.path
+			opts.LevelOutputLevels = tt.levels
+			if _, err := opts.levelOutputs(); err == nil {
+				t.Fatal("invalid level outputs accepted")
+			}
+		})
+	}
+}
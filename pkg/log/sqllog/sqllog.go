@@ -0,0 +1,249 @@
+// Package sqllog wraps database/sql drivers so that the queries are logged
+// with the context logger of the log package.
+package sqllog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	log "git.enn-edge.com/device_manage/public/log.git"
+)
+
+type options struct {
+	slowThreshold time.Duration
+	sampling      uint64
+	redact        func(query string, arg driver.NamedValue) bool
+}
+
+// Option optional parameters for Wrap, WrapConnector and Register
+type Option func(*options)
+
+// WithSlowThreshold logs the queries lasting at least d at warn level,
+// the other ones are logged at debug level
+func WithSlowThreshold(d time.Duration) Option {
+	return func(o *options) {
+		o.slowThreshold = d
+	}
+}
+
+// WithSampling logs one out of n queries which are neither slow nor failed
+func WithSampling(n int) Option {
+	return func(o *options) {
+		if n > 1 {
+			o.sampling = uint64(n)
+		}
+	}
+}
+
+// WithRedactedArgs logs the args matched by match as log.Redacted, all of
+// them if match is nil
+func WithRedactedArgs(match func(query string, arg driver.NamedValue) bool) Option {
+	return func(o *options) {
+		if match == nil {
+			match = func(string, driver.NamedValue) bool { return true }
+		}
+		o.redact = match
+	}
+}
+
+// queryLogger logs the queries of a wrapped driver
+type queryLogger struct {
+	opts    options
+	queries uint64
+}
+
+func newQueryLogger(opts []Option) *queryLogger {
+	o := options{sampling: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &queryLogger{opts: o}
+}
+
+// log logs a query through the context logger, at error level if it
+// failed, at warn level if it is slow and at debug level otherwise
+func (l *queryLogger) log(ctx context.Context, start time.Time, query string, args []driver.NamedValue,
+	result driver.Result, err error,
+) {
+	// the driver doesn't support the call, database/sql falls back on another one
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+
+	duration := time.Since(start)
+	logger := log.ContextLogger(ctx)
+
+	lvl := log.DebugLevel
+	switch {
+	case err != nil:
+		lvl = log.ErrorLevel
+	case l.opts.slowThreshold > 0 && duration >= l.opts.slowThreshold:
+		lvl = log.WarnLevel
+	case l.opts.sampling > 1 && atomic.AddUint64(&l.queries, 1)%l.opts.sampling != 1:
+		return
+	}
+
+	if !logger.Enabled(lvl) {
+		return
+	}
+	logger = logger.WithCallerSkip(callerSkip())
+
+	fields := []log.Field{
+		zap.String("query", query),
+		zap.Any("args", l.args(query, args)),
+		zap.Duration("duration", duration),
+	}
+	if result != nil {
+		if rows, rerr := result.RowsAffected(); rerr == nil {
+			fields = append(fields, zap.Int64("rows_affected", rows))
+		}
+	}
+
+	switch lvl {
+	case log.ErrorLevel:
+		logger.Error("sql query failed", append(fields, zap.Error(err))...)
+	case log.WarnLevel:
+		logger.Warn("slow sql query", fields...)
+	default:
+		logger.Debug("sql query", fields...)
+	}
+}
+
+// sqlFramePrefixes are the prefixes of the functions between the caller of
+// database/sql and queryLogger.log
+var sqlFramePrefixes = []string{
+	"database/sql.",
+	reflect.TypeOf(queryLogger{}).PkgPath() + ".",
+}
+
+// callerSkip returns the frames the logger of queryLogger.log skips so
+// that the caller of database/sql is reported rather than this package
+func callerSkip() int {
+	// skip runtime.Callers, callerSkip and queryLogger.log
+	var pcs [64]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+
+	for skip := 1; ; skip++ {
+		frame, more := frames.Next()
+		if !isSQLFrame(frame.Function) {
+			return skip
+		}
+
+		if !more {
+			return 0
+		}
+	}
+}
+
+func isSQLFrame(function string) bool {
+	for _, prefix := range sqlFramePrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *queryLogger) args(query string, args []driver.NamedValue) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if l.opts.redact != nil && l.opts.redact(query, arg) {
+			values[i] = log.Redacted
+		} else {
+			values[i] = arg.Value
+		}
+	}
+
+	return values
+}
+
+// Register registers the driver wrapped by Wrap as a database/sql driver
+// named name.
+func Register(name string, d driver.Driver, opts ...Option) {
+	sql.Register(name, Wrap(d, opts...))
+}
+
+// Wrap returns a driver whose connections log their queries.
+func Wrap(d driver.Driver, opts ...Option) driver.Driver {
+	return &wrappedDriver{Driver: d, logger: newQueryLogger(opts)}
+}
+
+// WrapConnector returns a connector whose connections log their queries,
+// for sql.OpenDB.
+func WrapConnector(c driver.Connector, opts ...Option) driver.Connector {
+	d := &wrappedDriver{Driver: c.Driver(), logger: newQueryLogger(opts)}
+
+	return &connector{Connector: c, driver: d}
+}
+
+type wrappedDriver struct {
+	driver.Driver
+	logger *queryLogger
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{Conn: c, logger: d.logger}, nil
+}
+
+func (d *wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	dc, ok := d.Driver.(driver.DriverContext)
+	if !ok {
+		return &connector{Connector: dsnConnector{name: name, driver: d.Driver}, driver: d}, nil
+	}
+
+	c, err := dc.OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connector{Connector: c, driver: d}, nil
+}
+
+type connector struct {
+	driver.Connector
+	driver *wrappedDriver
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{Conn: dc, logger: c.driver.logger}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// dsnConnector connects with the drivers which don't implement
+// driver.DriverContext
+type dsnConnector struct {
+	name   string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
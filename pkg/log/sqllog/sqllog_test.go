@@ -0,0 +1,231 @@
+package sqllog_test
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	log "git.enn-edge.com/device_manage/public/log.git"
+	"git.enn-edge.com/device_manage/public/log.git/sqllog"
+)
+
+// fakeDriver has connections preparing the statements, which convert the
+// string args to upper case with a driver.ColumnConverter
+type fakeDriver struct {
+	mu   sync.Mutex
+	args [][]driver.Value
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+func (d *fakeDriver) executed() [][]driver.Value {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([][]driver.Value(nil), d.args...)
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fake driver without transactions")
+}
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return 1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.HasPrefix(s.query, "FAIL") {
+		return nil, errors.New("fake failure")
+	}
+
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+
+	s.d.args = append(s.d.args, args)
+
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+func (s *fakeStmt) ColumnConverter(int) driver.ValueConverter {
+	return upperConverter{}
+}
+
+type upperConverter struct{}
+
+func (upperConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	if s, ok := v.(string); ok {
+		return strings.ToUpper(s), nil
+	}
+
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return []string{"n"} }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+var fake = &fakeDriver{}
+
+func init() {
+	sqllog.Register("sqllog-fake", fake)
+}
+
+// openDB opens a database of the fake driver, whose queries are logged
+// by the default logger to the returned file
+func openDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+
+	opts := log.NewOptions()
+	opts.Format = "json"
+	opts.Level = "debug"
+	opts.OutputPaths = []string{filepath.Join(t.TempDir(), "sql.log")}
+	_ = log.ResetDefault(opts).Close()
+	t.Cleanup(func() { _ = log.ResetDefault(log.NewOptions()).Close() })
+
+	db, err := sql.Open("sqllog-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db, opts.OutputPaths[0]
+}
+
+func readEntries(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+
+	log.Flush()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestColumnConverter(t *testing.T) {
+	db, _ := openDB(t)
+
+	if _, err := db.ExecContext(context.Background(), "UPDATE users SET name = ?", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the args are converted by the statement, not by database/sql
+	executed := fake.executed()
+	if len(executed) == 0 || executed[len(executed)-1][0] != "BOB" {
+		t.Fatalf("executed with %v, want the arg converted by the statement", executed)
+	}
+}
+
+func TestQueryEntries(t *testing.T) {
+	db, path := openDB(t)
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, "UPDATE users SET name = ?", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "FAIL users", "bob"); err == nil {
+		t.Fatal("failing query succeeded")
+	}
+	rows, err := db.QueryContext(ctx, "SELECT n FROM users WHERE name = ?", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	entries := readEntries(t, path)
+	if len(entries) != 3 {
+		t.Fatalf("%d entries, want 3: %v", len(entries), entries)
+	}
+
+	want := []struct{ level, message, query string }{
+		{"DEBUG", "sql query", "UPDATE users SET name = ?"},
+		{"ERROR", "sql query failed", "FAIL users"},
+		{"DEBUG", "sql query", "SELECT n FROM users WHERE name = ?"},
+	}
+	for i, entry := range entries {
+		if entry["level"] != want[i].level || entry["message"] != want[i].message || entry["query"] != want[i].query {
+			t.Errorf("entry %d: %v, want %+v", i, entry, want[i])
+		}
+
+		// the caller is the caller of database/sql
+		if caller, _ := entry["caller"].(string); !strings.HasPrefix(caller, "sqllog/sqllog_test.go:") {
+			t.Errorf("entry %d reported caller %q, want the test", i, caller)
+		}
+	}
+}
+
+func TestContextLogger(t *testing.T) {
+	db, path := openDB(t)
+
+	opts := log.NewOptions()
+	opts.Format = "json"
+	opts.Level = "debug"
+	opts.OutputPaths = []string{filepath.Join(t.TempDir(), "context.log")}
+	logger := log.New(opts)
+	defer logger.Close()
+
+	ctx := logger.WithName("db").WithContext(context.Background())
+	if _, err := db.ExecContext(ctx, "UPDATE users SET name = ?", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	logger.Flush()
+
+	// the query is logged by the logger of the context, not the default one
+	if entries := readEntries(t, path); len(entries) != 0 {
+		t.Fatalf("default logger has %v", entries)
+	}
+	entries := readEntries(t, opts.OutputPaths[0])
+	if len(entries) != 1 || entries[0]["logger"] != "db" || entries[0]["query"] != "UPDATE users SET name = ?" {
+		t.Fatalf("context logger has %v, want the query entry of the db logger", entries)
+	}
+}
@@ -0,0 +1,162 @@
+package log
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// stackFramesSize is the number of frames captured at first, more are
+// captured for deeper stacks
+const stackFramesSize = 64
+
+// internalFrames are the prefixes of the functions dropped from the stack
+// traces: the runtime, zap and this package
+var internalFrames = []string{
+	"runtime.",
+	"runtime/",
+	"go.uber.org/zap.",
+	"go.uber.org/zap/",
+	funcPrefix(reflect.TypeOf(zapLogger{}).PkgPath()),
+}
+
+// stacktraceLevel returns the level from which the entries carry a stack
+// trace, panic by default and warn in development like zap
+func stacktraceLevel(opts *Options) Level {
+	if opts.StacktraceLevel != "" {
+		if l, err := ParseLevel(opts.StacktraceLevel); err == nil {
+			return l
+		}
+	}
+
+	if opts.Development {
+		return WarnLevel
+	}
+
+	return PanicLevel
+}
+
+// stackCore records the stack traces of the entries at or above its
+// level, without the internal frames. It replaces zap's stack traces,
+// which start with them.
+type stackCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+	// maxDepth caps the frames of a stack trace, 0 means no limit
+	maxDepth int
+}
+
+func newStackCore(core zapcore.Core, level zapcore.LevelEnabler, maxDepth int) *stackCore {
+	return &stackCore{
+		Core:     core,
+		level:    level,
+		maxDepth: maxDepth,
+	}
+}
+
+func (c *stackCore) With(fields []zapcore.Field) zapcore.Core {
+	return newStackCore(c.Core.With(fields), c.level, c.maxDepth)
+}
+
+// Check records the stack trace on the entry the wrapped cores check, as
+// it is the entry they add to the checked entry
+func (c *stackCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Stack == "" && c.level.Enabled(ent.Level) && c.Core.Enabled(ent.Level) {
+		ent.Stack = takeStacktrace(c.maxDepth)
+	}
+
+	return c.Core.Check(ent, ce)
+}
+
+func (c *stackCore) elevate() zapcore.Core {
+	return newStackCore(elevateCore(c.Core), c.level, c.maxDepth)
+}
+
+// recordStack records the stack trace of an entry checked by a core
+// wrapping c and writing the entry later, such as bufferCore
+func recordStack(c zapcore.Core, ent zapcore.Entry) zapcore.Entry {
+	if sc, ok := c.(*stackCore); ok && ent.Stack == "" && sc.level.Enabled(ent.Level) {
+		ent.Stack = takeStacktrace(sc.maxDepth)
+	}
+
+	return ent
+}
+
+// errorWithStack logs an error entry carrying the stack trace of the
+// caller whatever the stacktrace level is, unless stack traces are disabled
+func (l *zapLogger) errorWithStack(msg string, fields ...Field) {
+	if l.span != nil {
+		l.logToSpan("error", msg, fields...)
+	}
+
+	ce := l.zapLogger.Check(ErrorLevel, msg)
+	if ce == nil {
+		return
+	}
+
+	// stackCore already recorded it at or above the stacktrace level
+	if ce.Stack == "" && !l.disableStacktrace {
+		ce.Stack = takeStacktrace(l.stacktraceMaxDepth)
+	}
+	ce.Write(fields...)
+}
+
+// takeStacktrace returns the stack trace of the caller in the format of
+// zap, up to maxDepth frames after dropping the internal ones
+func takeStacktrace(maxDepth int) string {
+	pcs := make([]uintptr, stackFramesSize)
+	for {
+		// skip runtime.Callers and takeStacktrace
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+
+			break
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+
+	var b strings.Builder
+	depth := 0
+	frames := runtime.CallersFrames(pcs)
+	for more := true; more && (maxDepth <= 0 || depth < maxDepth); {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		if internalFrame(frame.Function) {
+			continue
+		}
+
+		if depth > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(frame.Line))
+		depth++
+	}
+
+	return b.String()
+}
+
+// funcPrefix returns the prefix of the function names of a package, the
+// dots of its last path element are escaped in them
+func funcPrefix(pkgPath string) string {
+	i := strings.LastIndex(pkgPath, "/")
+
+	return pkgPath[:i+1] + strings.ReplaceAll(pkgPath[i+1:], ".", "%2e") + "."
+}
+
+func internalFrame(function string) bool {
+	for _, prefix := range internalFrames {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
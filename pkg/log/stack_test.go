@@ -0,0 +1,115 @@
+package log_test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	log "git.enn-edge.com/device_manage/public/log.git"
+)
+
+// logError logs an error entry from a function of its own, it is the
+// first frame of the stack trace
+func logError(l log.Logger) {
+	l.Error("failed")
+}
+
+// here returns the short caller of its caller, a line offset apart
+func here(offset int) string {
+	_, file, line, _ := runtime.Caller(1)
+	i := strings.LastIndex(file, "/")
+	i = strings.LastIndex(file[:i], "/")
+
+	return fmt.Sprintf("%s:%d", file[i+1:], line+offset)
+}
+
+// stackFunctions returns the functions of a stack trace, one per frame
+func stackFunctions(t *testing.T, entry map[string]interface{}) []string {
+	t.Helper()
+
+	stack, ok := entry["stacktrace"].(string)
+	if !ok {
+		t.Fatalf("entry %v without stack trace", entry)
+	}
+
+	var functions []string
+	for _, line := range strings.Split(stack, "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			functions = append(functions, line)
+		}
+	}
+
+	return functions
+}
+
+func TestStacktraceInternalFrames(t *testing.T) {
+	l, entries := newLogger(t, func(opts *log.Options) { opts.StacktraceLevel = "error" })
+	logError(l)
+
+	got := entries()
+	if len(got) != 1 {
+		t.Fatalf("%d entries, want 1", len(got))
+	}
+
+	// the frames of the runtime, zap and the logger are dropped
+	functions := stackFunctions(t, got[0])
+	if !strings.HasSuffix(functions[0], "_test.logError") {
+		t.Fatalf("stack trace starts at %s, want the caller of the logger", functions[0])
+	}
+	for _, function := range functions {
+		for _, internal := range []string{"runtime.", "go.uber.org/zap", "log%2egit."} {
+			if strings.Contains(function, internal) && !strings.Contains(function, "_test.") {
+				t.Errorf("internal frame %s in the stack trace", function)
+			}
+		}
+	}
+}
+
+func TestStacktraceMaxDepth(t *testing.T) {
+	l, entries := newLogger(t, func(opts *log.Options) {
+		opts.StacktraceLevel = "error"
+		opts.StacktraceMaxDepth = 2
+	})
+	logError(l)
+
+	functions := stackFunctions(t, entries()[0])
+	if len(functions) != 2 || !strings.HasSuffix(functions[1], "_test.TestStacktraceMaxDepth") {
+		t.Fatalf("stack trace of %v, want the two first frames", functions)
+	}
+}
+
+func TestDisableStacktrace(t *testing.T) {
+	l, entries := newLogger(t, func(opts *log.Options) {
+		opts.StacktraceLevel = "error"
+		opts.DisableStacktrace = true
+	})
+	l.Error("failed")
+	caller := here(-1)
+
+	got := entries()
+	if len(got) != 1 || got[0]["stacktrace"] != nil {
+		t.Fatalf("entries %v, want no stack trace", got)
+	}
+	if got[0]["caller"] != caller {
+		t.Fatalf("caller %v, want %s", got[0]["caller"], caller)
+	}
+}
+
+// logFromHelper logs with the caller of the helper as caller
+func logFromHelper(l log.Logger) {
+	l.WithCallerSkip(1).Info("from helper")
+}
+
+func TestWithCallerSkip(t *testing.T) {
+	l, entries := newLogger(t, nil)
+	logFromHelper(l)
+	caller := here(-1)
+	l.WithCallerSkip(0).Info("direct")
+	direct := here(-1)
+
+	got := entries()
+	if len(got) != 2 || got[0]["caller"] != caller || got[1]["caller"] != direct {
+		t.Fatalf("entries %v, want the callers %s and %s", got, caller, direct)
+	}
+}
@@ -0,0 +1,331 @@
+package log
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// TenantPlaceholder is replaced by the tenant in TenantOutputPath
+const TenantPlaceholder = "{tenant}"
+
+// validTenant matches the tenants which can be put in a path, the entries
+// of the other ones go to the output paths
+var validTenant = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// tenantRouter holds the sinks of the tenants, the least recently used
+// ones are closed beyond maxOpen
+type tenantRouter struct {
+	// opts open the sinks, path and policy are their templates
+	opts    *Options
+	path    string
+	policy  string
+	maxOpen int
+	level   Level
+	levels  map[string]Level
+	// minLevel is the lowest of level and levels
+	minLevel Level
+	errSink  zapcore.WriteSyncer
+
+	mu     sync.Mutex
+	lru    *list.List
+	sinks  map[string]*list.Element
+	closed bool
+}
+
+type tenantSink struct {
+	tenant string
+	sink   *guardedSink
+}
+
+func newTenantRouter(opts *Options, level Level, errSink zapcore.WriteSyncer) (*tenantRouter, error) {
+	if !strings.Contains(opts.TenantOutputPath, TenantPlaceholder) {
+		return nil, fmt.Errorf("tenant output path without %s: %q", TenantPlaceholder, opts.TenantOutputPath)
+	}
+
+	r := &tenantRouter{
+		opts:     opts,
+		path:     opts.TenantOutputPath,
+		policy:   opts.SinkPolicies[opts.TenantOutputPath],
+		maxOpen:  opts.TenantMaxOpenFiles,
+		level:    level,
+		levels:   make(map[string]Level, len(opts.TenantLevels)),
+		minLevel: level,
+		errSink:  errSink,
+		lru:      list.New(),
+		sinks:    map[string]*list.Element{},
+	}
+	if r.maxOpen < 1 {
+		r.maxOpen = 1
+	}
+
+	for tenant, text := range opts.TenantLevels {
+		l, err := ParseLevel(text)
+		if err != nil {
+			return nil, fmt.Errorf("level of tenant %q: %w", tenant, err)
+		}
+
+		r.levels[tenant] = l
+		if levelRank(l) < levelRank(r.minLevel) {
+			r.minLevel = l
+		}
+	}
+
+	return r, nil
+}
+
+// levelOf returns the level of a tenant, its override or the logger level
+func (r *tenantRouter) levelOf(tenant string) Level {
+	if l, ok := r.levels[tenant]; ok {
+		return l
+	}
+
+	return r.level
+}
+
+// write writes an encoded entry to the sink of the tenant, opened if needed
+func (r *tenantRouter) write(tenant string, p []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return errSinkClosed
+	}
+
+	if e, ok := r.sinks[tenant]; ok {
+		r.lru.MoveToFront(e)
+		_, err := e.Value.(*tenantSink).sink.Write(p)
+
+		return err
+	}
+
+	// the sinks of the tenants apply the policy of the template, the
+	// secondary sink of failover may be a template too
+	expand := func(s string) string {
+		return strings.ReplaceAll(s, TenantPlaceholder, tenant)
+	}
+	sink, err := openPolicySink(r.opts, expand(r.path), expand(r.policy))
+	if err != nil {
+		return err
+	}
+
+	r.sinks[tenant] = r.lru.PushFront(&tenantSink{tenant: tenant, sink: sink})
+	for r.lru.Len() > r.maxOpen {
+		oldest := r.lru.Remove(r.lru.Back()).(*tenantSink)
+		delete(r.sinks, oldest.tenant)
+		_ = oldest.sink.Close()
+	}
+
+	_, err = sink.Write(p)
+
+	return err
+}
+
+func (r *tenantRouter) sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	for e := r.lru.Front(); e != nil; e = e.Next() {
+		err = multierr.Append(err, e.Value.(*tenantSink).sink.Sync())
+	}
+
+	return err
+}
+
+// statuses returns the health of the open sinks of the tenants, the most
+// recently used first
+func (r *tenantRouter) statuses() []SinkStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]SinkStatus, 0, r.lru.Len())
+	for e := r.lru.Front(); e != nil; e = e.Next() {
+		statuses = append(statuses, e.Value.(*tenantSink).sink.snapshot())
+	}
+
+	return statuses
+}
+
+// close syncs and closes the sinks of the tenants, the later entries of
+// the tenants fail
+func (r *tenantRouter) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	for e := r.lru.Front(); e != nil; e = e.Next() {
+		err = multierr.Append(err, e.Value.(*tenantSink).sink.Close())
+	}
+	r.lru.Init()
+	r.sinks = map[string]*list.Element{}
+	r.closed = true
+
+	return err
+}
+
+// tenantCore writes the entries carrying the tenant field to the sink of
+// their tenant, at the level of the tenant, and the other entries to the
+// wrapped core. It wraps the core filtered by the logger level so that a
+// tenant may log below it.
+//
+// The entries of the tenants only go to the sinks of their tenants: they
+// bypass the sampler, the OTLP export and the files split by level of the
+// wrapped core, a tenant logging below the logger level would flood them
+// otherwise. The ring buffer, teed around this core, records them.
+type tenantCore struct {
+	zapcore.Core
+	router *tenantRouter
+	key    string
+	// enc encodes the entries of the tenants with the fields of With
+	enc zapcore.Encoder
+	// tenant is set by a tenant field passed to With
+	tenant   string
+	elevated bool
+}
+
+func newTenantCore(core zapcore.Core, enc zapcore.Encoder, key string, router *tenantRouter) *tenantCore {
+	return &tenantCore{
+		Core:   core,
+		router: router,
+		key:    key,
+		enc:    enc.Clone(),
+	}
+}
+
+func (c *tenantCore) levelOf(tenant string) zapcore.LevelEnabler {
+	if c.elevated {
+		return levelEnabler(elevatedLevel(c.router.levelOf(tenant)))
+	}
+
+	return levelEnabler(c.router.levelOf(tenant))
+}
+
+func (c *tenantCore) Enabled(lvl zapcore.Level) bool {
+	return c.Core.Enabled(lvl) || c.levelOf(c.tenant).Enabled(lvl) || levelEnabler(c.router.minLevel).Enabled(lvl)
+}
+
+func (c *tenantCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	clone.enc = c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+	if tenant, ok := c.tenantOf(fields); ok {
+		clone.tenant = tenant
+	}
+
+	return &clone
+}
+
+// Check defers the routing to Write when the tenant isn't known yet, it
+// may be a field of the entry
+func (c *tenantCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.tenant != "" {
+		if c.levelOf(c.tenant).Enabled(ent.Level) {
+			return ce.AddCore(ent, c)
+		}
+
+		return ce
+	}
+
+	if c.Core.Enabled(ent.Level) || levelEnabler(c.router.minLevel).Enabled(ent.Level) || c.elevated {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *tenantCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	tenant := c.tenant
+	if t, ok := c.tenantOf(fields); ok {
+		tenant = t
+	}
+
+	if tenant == "" {
+		// the untagged entries go through the checks of the wrapped core,
+		// sampling and level included
+		if ce := c.Core.Check(ent, nil); ce != nil {
+			ce.ErrorOutput = c.router.errSink
+			ce.Write(fields...)
+		}
+
+		return nil
+	}
+
+	if !c.levelOf(tenant).Enabled(ent.Level) {
+		return nil
+	}
+
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	return c.router.write(tenant, buf.Bytes())
+}
+
+func (c *tenantCore) Sync() error {
+	return multierr.Append(c.Core.Sync(), c.router.sync())
+}
+
+func (c *tenantCore) elevate() zapcore.Core {
+	clone := *c
+	clone.Core = elevateCore(c.Core)
+	clone.elevated = true
+
+	return &clone
+}
+
+// tenantOf returns the tenant of the last tenant field, empty if it isn't
+// valid in a path
+func (c *tenantCore) tenantOf(fields []zapcore.Field) (string, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		f := fields[i]
+		if f.Key != c.key {
+			continue
+		}
+
+		var tenant string
+		switch f.Type {
+		case zapcore.StringType:
+			tenant = f.String
+		case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+			tenant = strconv.FormatInt(f.Integer, 10)
+		case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+			tenant = strconv.FormatUint(uint64(f.Integer), 10)
+		case zapcore.StringerType:
+			if s, ok := f.Interface.(fmt.Stringer); ok {
+				tenant = stringOf(s)
+			}
+		}
+
+		if !validTenant.MatchString(tenant) {
+			tenant = ""
+		}
+
+		return tenant, true
+	}
+
+	return "", false
+}
+
+// stringOf returns s.String(), empty if it panics: the entry then goes to
+// the output paths, where the encoder reports the panic in the field
+func stringOf(s fmt.Stringer) (str string) {
+	defer func() {
+		if r := recover(); r != nil {
+			str = ""
+		}
+	}()
+
+	return s.String()
+}
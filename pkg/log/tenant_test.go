@@ -0,0 +1,179 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newTenantLogger creates a logger of the info level routing the tenant
+// field, and returns a function reading the entries of a tenant
+func newTenantLogger(t *testing.T, configure func(*Options)) (*zapLogger, func() []map[string]interface{}, func(string) []map[string]interface{}) {
+	t.Helper()
+
+	opts := newTestOptions(t)
+	opts.Level = "info"
+	opts.TenantField = "tenant"
+	opts.TenantOutputPath = filepath.Join(t.TempDir(), TenantPlaceholder+".log")
+	if configure != nil {
+		configure(opts)
+	}
+	l, entries := newTestLogger(t, opts)
+
+	return l, entries, func(tenant string) []map[string]interface{} {
+		t.Helper()
+
+		l.Flush()
+
+		return readEntries(t, filepath.Join(filepath.Dir(opts.TenantOutputPath), tenant+".log"))
+	}
+}
+
+func TestTenantLevels(t *testing.T) {
+	l, entries, tenantEntries := newTenantLogger(t, func(opts *Options) {
+		opts.TenantLevels = map[string]string{"verbose": "trace", "quiet": "warn"}
+	})
+
+	for _, tenant := range []string{"verbose", "quiet", "other"} {
+		tl := l.WithValues("tenant", tenant)
+		tl.Trace("trace")
+		tl.Info("info")
+		tl.Warn("warn")
+	}
+
+	want := map[string][]string{
+		"verbose": {"trace", "info", "warn"},
+		"quiet":   {"warn"},
+		"other":   {"info", "warn"},
+	}
+	for tenant, messages := range want {
+		if got := entryMessages(tenantEntries(tenant)); !equalStrings(got, messages) {
+			t.Errorf("tenant %s has %v, want %v", tenant, got, messages)
+		}
+	}
+
+	// the entries of the tenants don't go to the output paths
+	if got := entries(); len(got) != 0 {
+		t.Fatalf("output has %v, want no tenant entry", got)
+	}
+}
+
+func TestTenantUntagged(t *testing.T) {
+	l, entries, tenantEntries := newTenantLogger(t, nil)
+
+	l.Debug("debug")
+	l.Info("untagged")
+	l.Info("invalid", zap.String("tenant", "../etc"))
+	l.Info("tagged", zap.String("tenant", "t1"))
+
+	// the untagged entries and the invalid tenants go to the output paths,
+	// at the logger level
+	if got := entryMessages(entries()); !equalStrings(got, []string{"untagged", "invalid"}) {
+		t.Fatalf("output has %v, want the untagged entries", got)
+	}
+	if got := entryMessages(tenantEntries("t1")); !equalStrings(got, []string{"tagged"}) {
+		t.Fatalf("tenant has %v, want the tagged entry", got)
+	}
+}
+
+type panickingStringer struct{}
+
+func (panickingStringer) String() string {
+	panic("boom")
+}
+
+func TestTenantPanickingStringer(t *testing.T) {
+	l, entries, _ := newTenantLogger(t, nil)
+
+	l.Info("stringer", zap.Stringer("tenant", panickingStringer{}))
+
+	got := entries()
+	if len(got) != 1 || got[0]["message"] != "stringer" {
+		t.Fatalf("output has %v, want the entry of the panicking tenant", got)
+	}
+}
+
+func TestTenantEviction(t *testing.T) {
+	opts := NewOptions()
+	opts.TenantOutputPath = filepath.Join(t.TempDir(), TenantPlaceholder+".log")
+	opts.TenantMaxOpenFiles = 2
+	r, err := newTenantRouter(opts, InfoLevel, zapcore.AddSync(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+
+	open := func() []string {
+		var tenants []string
+		for e := r.lru.Front(); e != nil; e = e.Next() {
+			tenants = append(tenants, e.Value.(*tenantSink).tenant)
+		}
+
+		return tenants
+	}
+
+	// the least recently used sink is closed, a write moves its sink first
+	for i, tt := range []struct {
+		tenant string
+		open   []string
+	}{
+		{"a", []string{"a"}},
+		{"b", []string{"b", "a"}},
+		{"c", []string{"c", "b"}},
+		{"b", []string{"b", "c"}},
+		{"a", []string{"a", "b"}},
+	} {
+		if err := r.write(tt.tenant, []byte(fmt.Sprintf("{\"message\":\"%d\"}\n", i))); err != nil {
+			t.Fatal(err)
+		}
+		if got := open(); !equalStrings(got, tt.open) || len(r.sinks) != len(tt.open) {
+			t.Fatalf("write %d: open sinks %v, want %v", i, got, tt.open)
+		}
+	}
+
+	// a reopened sink appends to its file
+	if err := r.sync(); err != nil {
+		t.Fatal(err)
+	}
+	got := readEntries(t, strings.ReplaceAll(opts.TenantOutputPath, TenantPlaceholder, "a"))
+	if messages := entryMessages(got); !equalStrings(messages, []string{"0", "4"}) {
+		t.Fatalf("tenant a has %v, want the entries before and after its eviction", messages)
+	}
+}
+
+func TestTenantSinkPolicy(t *testing.T) {
+	var tenantPath string
+	l, _, tenantEntries := newTenantLogger(t, func(opts *Options) {
+		tenantPath = opts.TenantOutputPath
+		opts.SinkPolicies = map[string]string{tenantPath: SinkPolicyDrop}
+		if errs := opts.Validate(); len(errs) != 0 {
+			t.Fatalf("policy of the tenant template rejected: %v", errs)
+		}
+	})
+
+	l.WithValues("tenant", "a").Info("written")
+	if got := entryMessages(tenantEntries("a")); !equalStrings(got, []string{"written"}) {
+		t.Fatalf("tenant a has %v", got)
+	}
+
+	// the sink of the tenant applies the policy of the template and reports
+	// its health with the other sinks
+	statuses := l.SinkStatuses()
+	tenant := statuses[len(statuses)-1]
+	if want := strings.ReplaceAll(tenantPath, TenantPlaceholder, "a"); tenant.Path != want || tenant.Policy != SinkPolicyDrop || !tenant.Healthy {
+		t.Fatalf("tenant sink status %+v", tenant)
+	}
+
+	l.tenants.sinks["a"].Value.(*tenantSink).sink.ws = &testSink{failing: true}
+	l.WithValues("tenant", "a").Info("dropped")
+
+	statuses = l.SinkStatuses()
+	if tenant := statuses[len(statuses)-1]; tenant.Healthy || tenant.Dropped != 1 {
+		t.Fatalf("failing tenant sink status %+v", tenant)
+	}
+}
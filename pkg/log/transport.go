@@ -0,0 +1,323 @@
+package log
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// redactedHeaders are the headers whose values are never logged
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// WithRequestID stores the request ID in ctx, under the key of the
+// requestID common field so that C logs it.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	// the common fields are looked up by their names
+	return context.WithValue(ctx, keyRequestID, requestID)
+}
+
+// RequestID returns the request ID stored in ctx by WithRequestID
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(keyRequestID).(string)
+
+	return requestID
+}
+
+type transportOptions struct {
+	maxRequestBody  int
+	maxResponseBody int
+	logHeaders      bool
+	redacted        map[string]bool
+	maxRetries      int
+	retryBackoff    time.Duration
+}
+
+// TransportOption optional parameters for NewTransport
+type TransportOption func(*transportOptions)
+
+// WithBodyCapture logs up to maxRequest bytes of the request bodies and up
+// to maxResponse bytes of the response bodies, 0 disables the capture
+func WithBodyCapture(maxRequest, maxResponse int) TransportOption {
+	return func(o *transportOptions) {
+		o.maxRequestBody = maxRequest
+		o.maxResponseBody = maxResponse
+	}
+}
+
+// WithHeaderLogging logs the headers of the requests and responses, the
+// values of the authentication headers and of the redacted ones are
+// replaced by Redacted
+func WithHeaderLogging(redacted ...string) TransportOption {
+	return func(o *transportOptions) {
+		o.logHeaders = true
+		for _, h := range redacted {
+			o.redacted[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+}
+
+// WithRetries retries the idempotent requests up to n times on transport
+// errors and on 502, 503 and 504 responses, with an exponential backoff
+func WithRetries(n int, backoff time.Duration) TransportOption {
+	return func(o *transportOptions) {
+		o.maxRetries = n
+		o.retryBackoff = backoff
+	}
+}
+
+// transport logs the outbound requests and propagates the request ID and
+// the trace of their context
+type transport struct {
+	base http.RoundTripper
+	opts *transportOptions
+}
+
+// NewTransport wraps base, http.DefaultTransport if nil, so that the
+// outbound requests carry the request ID and the span of their context in
+// HeaderRequestID and trace headers, and are logged by the logger of their
+// context stored by WithContext, the default logger without one, with the
+// common fields and the span of the context. With a response body
+// capture, the requests are logged once their response body is read to
+// its end or closed.
+func NewTransport(base http.RoundTripper, opts ...TransportOption) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	o := &transportOptions{redacted: map[string]bool{}}
+	for _, h := range redactedHeaders {
+		o.redacted[h] = true
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &transport{base: base, opts: o}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// a RoundTripper must not modify the request
+	req = req.Clone(ctx)
+	if requestID := RequestID(ctx); requestID != "" && req.Header.Get(HeaderRequestID) == "" {
+		req.Header.Set(HeaderRequestID, requestID)
+	}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		_ = span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+	}
+
+	fields := []Field{
+		zap.String("method", req.Method),
+		zap.String("host", req.URL.Host),
+		zap.String("path", req.URL.Path),
+	}
+	if t.opts.logHeaders {
+		fields = append(fields, zap.Any("request_headers", t.headers(req.Header)))
+	}
+	if body := t.requestBody(req); body != nil {
+		fields = append(fields, zap.ByteString("request_body", body))
+	}
+
+	start := time.Now()
+	resp, retries, err := t.roundTrip(req)
+	fields = append(fields, zap.Duration("latency", time.Since(start)), zap.Int("retries", retries))
+
+	logger := ContextLogger(ctx)
+	if err != nil {
+		logger.Error("outbound request failed", append(fields, zap.Error(err))...)
+
+		return nil, err
+	}
+
+	fields = append(fields, zap.Int("status", resp.StatusCode))
+	if t.opts.logHeaders {
+		fields = append(fields, zap.Any("response_headers", t.headers(resp.Header)))
+	}
+
+	logResponse := func(body []byte) {
+		if body != nil {
+			fields = append(fields, zap.ByteString("response_body", body))
+		}
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			logger.Warn("outbound request", fields...)
+		} else {
+			logger.Info("outbound request", fields...)
+		}
+	}
+
+	if !t.captureResponseBody(resp, logResponse) {
+		logResponse(nil)
+	}
+
+	return resp, nil
+}
+
+// roundTrip sends the request, retrying it if allowed, and returns the
+// number of retries
+func (t *transport) roundTrip(req *http.Request) (*http.Response, int, error) {
+	backoff := t.opts.retryBackoff
+	for retries := 0; ; retries++ {
+		resp, err := t.base.RoundTrip(req)
+		if retries >= t.opts.maxRetries || !retryable(req, resp, err) {
+			return resp, retries, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, retries, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, retries, err
+			}
+		}
+	}
+}
+
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+	default:
+		return false
+	}
+
+	// the body can't be sent again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// headers returns the headers to log, with the values of the redacted ones
+// replaced
+func (t *transport) headers(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for k, v := range h {
+		if t.opts.redacted[k] {
+			headers[k] = Redacted
+		} else {
+			headers[k] = strings.Join(v, ", ")
+		}
+	}
+
+	return headers
+}
+
+// requestBody reads the beginning of the request body from a copy, the
+// body itself is left untouched
+func (t *transport) requestBody(req *http.Request) []byte {
+	if t.opts.maxRequestBody <= 0 || req.GetBody == nil {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+
+	captured, _ := io.ReadAll(io.LimitReader(body, int64(t.opts.maxRequestBody)))
+
+	return captured
+}
+
+// captureResponseBody tees the beginning of the response body as it is
+// read, and calls log with it once the body is read to its end or closed,
+// so that streamed responses aren't held back. It returns false if the
+// body isn't captured.
+func (t *transport) captureResponseBody(resp *http.Response, log func(body []byte)) bool {
+	if t.opts.maxResponseBody <= 0 || resp.Body == nil || resp.Body == http.NoBody {
+		return false
+	}
+
+	// the body of an upgraded connection is also written to
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		return false
+	}
+
+	resp.Body = &capturedBody{body: resp.Body, max: t.opts.maxResponseBody, log: log}
+
+	return true
+}
+
+// capturedBody keeps up to max bytes of the body read
+type capturedBody struct {
+	body io.ReadCloser
+	max  int
+	log  func(body []byte)
+
+	mu       sync.Mutex
+	captured []byte
+	logged   bool
+}
+
+func (b *capturedBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if rest := b.max - len(b.captured); rest > 0 && n > 0 {
+		if rest > n {
+			rest = n
+		}
+		b.captured = append(b.captured, p[:rest]...)
+	}
+
+	if err != nil {
+		b.done()
+	}
+
+	return n, err
+}
+
+func (b *capturedBody) Close() error {
+	err := b.body.Close()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.done()
+
+	return err
+}
+
+// done logs the captured body once, the caller holds the lock
+func (b *capturedBody) done() {
+	if b.logged {
+		return
+	}
+	b.logged = true
+
+	captured := b.captured
+	if captured == nil {
+		captured = []byte{}
+	}
+	b.log(captured)
+}
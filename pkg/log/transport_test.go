@@ -0,0 +1,194 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// entriesWithMessage returns the entries logged with msg
+func entriesWithMessage(entries []map[string]interface{}, msg string) []map[string]interface{} {
+	var found []map[string]interface{}
+	for _, entry := range entries {
+		if entry["message"] == msg {
+			found = append(found, entry)
+		}
+	}
+
+	return found
+}
+
+func TestTransportContextLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get(HeaderRequestID)))
+	}))
+	defer srv.Close()
+
+	l, entries := newTestLogger(t, nil)
+	ctx := l.WithContext(WithRequestID(context.Background(), "r1"))
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/users", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	client := &http.Client{Transport: NewTransport(nil, WithHeaderLogging())}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "r1" {
+		t.Fatalf("request ID %q sent, want r1", body)
+	}
+
+	// the entry is logged by the logger of the context, not the default one
+	got := entriesWithMessage(entries(), "outbound request")
+	if len(got) != 1 {
+		t.Fatalf("%d outbound entries, want 1", len(got))
+	}
+	entry := got[0]
+	if entry["method"] != "GET" || entry["path"] != "/users" || entry["status"] != float64(200) || entry["requestID"] != "r1" {
+		t.Fatalf("entry %v", entry)
+	}
+	if headers, _ := entry["request_headers"].(map[string]interface{}); headers["Authorization"] != Redacted {
+		t.Fatalf("request headers %v, want the authorization redacted", entry["request_headers"])
+	}
+}
+
+func TestTransportStreamedResponse(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = w.Write([]byte("second\n"))
+	}))
+	defer srv.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	l, entries := newTestLogger(t, nil)
+	req, _ := http.NewRequestWithContext(l.WithContext(context.Background()), http.MethodGet, srv.URL, nil)
+	client := &http.Client{Transport: NewTransport(nil, WithBodyCapture(0, 1024))}
+
+	// the response is returned before the end of its body
+	done := make(chan *http.Response)
+	go func() {
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- resp
+	}()
+
+	var resp *http.Response
+	select {
+	case resp = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("response held back by the body capture")
+	}
+	if resp == nil {
+		return
+	}
+
+	r := bufio.NewReader(resp.Body)
+	if line, err := r.ReadString('\n'); err != nil || line != "first\n" {
+		t.Fatalf("read %q, %v", line, err)
+	}
+	if got := entriesWithMessage(entries(), "outbound request"); len(got) != 0 {
+		t.Fatalf("entry logged before the end of the body: %v", got)
+	}
+
+	close(release)
+	rest, _ := io.ReadAll(r)
+	resp.Body.Close()
+	if string(rest) != "second\n" {
+		t.Fatalf("read %q", rest)
+	}
+
+	got := entriesWithMessage(entries(), "outbound request")
+	if len(got) != 1 || got[0]["response_body"] != "first\nsecond\n" {
+		t.Fatalf("entries %v, want one with the captured body", got)
+	}
+}
+
+func TestTransportBodyCaptureLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer srv.Close()
+
+	l, entries := newTestLogger(t, nil)
+	req, _ := http.NewRequestWithContext(l.WithContext(context.Background()), http.MethodPost, srv.URL, strings.NewReader("0123456789"))
+	client := &http.Client{Transport: NewTransport(nil, WithBodyCapture(4, 6))}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the body is returned whole, the capture is only logged
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "0123456789" {
+		t.Fatalf("body %q", body)
+	}
+
+	got := entriesWithMessage(entries(), "outbound request")
+	if len(got) != 1 || got[0]["request_body"] != "0123" || got[0]["response_body"] != "012345" {
+		t.Fatalf("entries %v", got)
+	}
+}
+
+func TestTransportRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+	}))
+	defer srv.Close()
+
+	l, entries := newTestLogger(t, nil)
+	req, _ := http.NewRequestWithContext(l.WithContext(context.Background()), http.MethodGet, srv.URL, nil)
+	client := &http.Client{Transport: NewTransport(nil, WithRetries(2, time.Millisecond))}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	got := entriesWithMessage(entries(), "outbound request")
+	if len(got) != 1 || got[0]["retries"] != float64(1) || got[0]["status"] != float64(200) {
+		t.Fatalf("entries %v, want one retry", got)
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	l, entries := newTestLogger(t, nil)
+	req, _ := http.NewRequestWithContext(l.WithContext(context.Background()), http.MethodGet, srv.URL, nil)
+	client := &http.Client{Transport: NewTransport(nil)}
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+
+	got := entriesWithMessage(entries(), "outbound request failed")
+	if len(got) != 1 || got[0]["level"] != "ERROR" || got[0]["error"] == nil {
+		t.Fatalf("entries %v, want the failure", got)
+	}
+}
@@ -1,7 +1,6 @@
 package log
 
 import (
-	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -9,5 +8,3 @@ type (
 	Field = zapcore.Field
 	Level = zapcore.Level
 )
-
-var Any = zap.Any
@@ -0,0 +1,194 @@
+package log
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// InfoLogger logs the entries of a verbosity level, see Logger.V
+type InfoLogger interface {
+	Info(msg string, fields ...Field)
+	Infof(format string, v ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+
+	// Enabled reports whether the verbosity level is enabled
+	Enabled() bool
+}
+
+// noopInfoLogger is returned by V for the disabled verbosity levels
+type noopInfoLogger struct{}
+
+func (noopInfoLogger) Info(string, ...Field)        {}
+func (noopInfoLogger) Infof(string, ...interface{}) {}
+func (noopInfoLogger) Infow(string, ...interface{}) {}
+func (noopInfoLogger) Enabled() bool                { return false }
+
+var disabledInfoLogger InfoLogger = noopInfoLogger{}
+
+// infoLogger logs the entries of a verbosity level through its logger, at
+// info level for V(0) and at debug level above
+type infoLogger struct {
+	logger *zapLogger
+	level  Level
+}
+
+func (l *infoLogger) Info(msg string, fields ...Field) {
+	if !l.logger.Enabled(l.level) {
+		return
+	}
+
+	if l.logger.span != nil {
+		l.logger.logToSpan(l.level.String(), msg, fields...)
+	}
+
+	l.logger.zapLogger.Log(l.level, msg, copyFields(fields)...)
+}
+
+func (l *infoLogger) Infof(format string, v ...interface{}) {
+	if !l.logger.Enabled(l.level) {
+		return
+	}
+
+	if l.level == DebugLevel {
+		l.logger.sugar.Debugf(format, v...)
+	} else {
+		l.logger.sugar.Infof(format, v...)
+	}
+}
+
+func (l *infoLogger) Infow(msg string, keysAndValues ...interface{}) {
+	if !l.logger.Enabled(l.level) {
+		return
+	}
+
+	l.logger.zapLogger.Log(l.level, msg, handleFields(l.logger.zapLogger, keysAndValues)...)
+}
+
+func (l *infoLogger) Enabled() bool {
+	return l.logger.Enabled(l.level)
+}
+
+// V returns an InfoLogger of the default logger for the verbosity level
+func V(level int) InfoLogger {
+	return std.V(level)
+}
+
+// V returns an InfoLogger for the verbosity level, which logs only if level
+// is at most the verbosity of the logger and the level of its entries is
+// enabled. V(0) logs at info level, higher levels are graded debug entries:
+// they are logged at debug level with a "v" field.
+func (l *zapLogger) V(level int) InfoLogger {
+	if level > l.verbosity.level(l.name) {
+		return disabledInfoLogger
+	}
+
+	if level <= 0 {
+		if !l.Enabled(InfoLevel) {
+			return disabledInfoLogger
+		}
+
+		return &infoLogger{logger: l, level: InfoLevel}
+	}
+
+	if !l.Enabled(DebugLevel) {
+		return disabledInfoLogger
+	}
+
+	return l.vLoggers.get(level, func() InfoLogger {
+		return &infoLogger{logger: l.derive(l.zapLogger.With(zap.Int("v", level))), level: DebugLevel}
+	})
+}
+
+// vLoggers caches the InfoLoggers V returns for the verbosity levels above
+// 0 of a logger, as they are built from a copy of it with the "v" field
+type vLoggers struct {
+	mu      sync.RWMutex
+	loggers map[int]InfoLogger
+}
+
+// get returns the InfoLogger of a verbosity level, created by create once
+func (c *vLoggers) get(level int, create func() InfoLogger) InfoLogger {
+	if c == nil {
+		return create()
+	}
+
+	c.mu.RLock()
+	l, ok := c.loggers[level]
+	c.mu.RUnlock()
+	if ok {
+		return l
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l, ok := c.loggers[level]; ok {
+		return l
+	}
+	if c.loggers == nil {
+		c.loggers = map[int]InfoLogger{}
+	}
+	l = create()
+	c.loggers[level] = l
+
+	return l
+}
+
+// verbosity is the verbosity of the loggers, overridden by logger name
+type verbosity struct {
+	v     int
+	rules []vmoduleRule
+}
+
+// vmoduleRule sets the verbosity of the loggers whose name matches pattern
+type vmoduleRule struct {
+	pattern string
+	v       int
+}
+
+// newVerbosity creates a verbosity from the default one and the
+// name=level overrides
+func newVerbosity(v int, vmodule []string) (*verbosity, error) {
+	vb := &verbosity{v: v}
+	for _, rule := range vmodule {
+		i := strings.LastIndexByte(rule, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid vmodule %q, expect NAME=LEVEL", rule)
+		}
+
+		pattern := rule[:i]
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid vmodule pattern %q: %w", pattern, err)
+		}
+
+		level, err := strconv.Atoi(rule[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid vmodule level %q: %w", rule, err)
+		}
+
+		vb.rules = append(vb.rules, vmoduleRule{pattern: pattern, v: level})
+	}
+
+	return vb, nil
+}
+
+// level returns the verbosity of the logger name, the first matching rule
+// applies. Logger names are matched with path.Match, e.g. "db.*".
+func (vb *verbosity) level(name string) int {
+	if vb == nil {
+		return 0
+	}
+
+	for _, rule := range vb.rules {
+		if matched, _ := path.Match(rule.pattern, name); matched {
+			return rule.v
+		}
+	}
+
+	return vb.v
+}
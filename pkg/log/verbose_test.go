@@ -0,0 +1,163 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"go.uber.org/zap"
+)
+
+func TestVerbosityLevel(t *testing.T) {
+	vb, err := newVerbosity(1, []string{"db=3", "api.*=2", "api.auth=5", "*.cache=4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want int
+	}{
+		{name: "", want: 1},
+		{name: "db", want: 3},
+		{name: "db.pool", want: 1},
+		{name: "api.users", want: 2},
+		// the first matching rule applies
+		{name: "api.auth", want: 2},
+		{name: "worker.cache", want: 4},
+		{name: "other", want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := vb.level(tt.name); got != tt.want {
+			t.Errorf("level of %q: %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVerbosityInvalid(t *testing.T) {
+	for _, vmodule := range []string{"db", "=1", "db=x", "[=1"} {
+		if _, err := newVerbosity(0, []string{vmodule}); err == nil {
+			t.Errorf("vmodule %q accepted", vmodule)
+		}
+	}
+}
+
+func TestV(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.Level = "debug"
+	opts.Verbosity = 1
+	opts.VModule = []string{"db=3"}
+	l, entries := newTestLogger(t, opts)
+
+	l.V(0).Info("v0")
+	l.V(1).Info("v1")
+	l.V(2).Info("v2")
+	db := l.WithName("db")
+	db.V(3).Infow("v3", "query", "select 1")
+	db.V(4).Info("v4")
+
+	got := entries()
+	if msgs := entryMessages(got); !equalStrings(msgs, []string{"v0", "v1", "v3"}) {
+		t.Fatalf("entries %v, want [v0 v1 v3]", msgs)
+	}
+	if got[1]["level"] != "DEBUG" || got[1]["v"] != float64(1) {
+		t.Fatalf("verbose entry %v, want debug with v", got[1])
+	}
+	if got[2]["logger"] != "db" || got[2]["query"] != "select 1" {
+		t.Fatalf("verbose entry %v of db", got[2])
+	}
+
+	// the loggers are cached per verbosity
+	if l.V(1) != l.V(1) || l.V(1) == l.V(0) {
+		t.Fatal("V loggers not cached")
+	}
+	if db.V(1) == l.V(1) {
+		t.Fatal("V loggers shared by derived loggers")
+	}
+}
+
+func TestVLevelDisabled(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.Level = "warn"
+	opts.Verbosity = 2
+	l, entries := newTestLogger(t, opts)
+
+	// the verbosity doesn't enable the levels disabled by the logger
+	for v := 0; v <= 2; v++ {
+		if l.V(v).Enabled() {
+			t.Fatalf("V(%d) enabled at warn level", v)
+		}
+		l.V(v).Info("info")
+		l.V(v).Infof("%s", "infof")
+		l.V(v).Infow("infow", "v", v)
+	}
+
+	if got := entries(); len(got) != 0 {
+		t.Fatalf("entries %v at warn level", got)
+	}
+}
+
+func TestVDebugDisabled(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.Level = "info"
+	opts.Verbosity = 2
+	l, entries := newTestLogger(t, opts)
+
+	if !l.V(0).Enabled() || l.V(1).Enabled() {
+		t.Fatal("V(0) disabled or V(1) enabled at info level")
+	}
+	l.V(0).Info("v0")
+	l.V(1).Info("v1")
+
+	if msgs := entryMessages(entries()); !equalStrings(msgs, []string{"v0"}) {
+		t.Fatalf("entries %v, want [v0]", msgs)
+	}
+}
+
+func TestVSpan(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.Level = "debug"
+	opts.Verbosity = 1
+	l, _ := newTestLogger(t, opts)
+
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+	span := tracer.StartSpan("op")
+	defer span.Finish()
+
+	sl := l.J(opentracing.ContextWithSpan(context.Background(), span))
+	sl.V(0).Info("v0")
+	sl.V(1).Info("v1", zap.String("query", "select 1"))
+
+	logs := span.(*jaeger.Span).Logs()
+	if len(logs) != 2 {
+		t.Fatalf("%d span logs, want 2", len(logs))
+	}
+	want := []map[string]string{
+		{"event": "v0", "level": "info"},
+		{"event": "v1", "level": "debug", "query": "select 1"},
+	}
+	for i, record := range logs {
+		got := map[string]interface{}{}
+		for _, field := range record.Fields {
+			got[field.Key()] = field.Value()
+		}
+		for key, value := range want[i] {
+			if got[key] != value {
+				t.Errorf("span log %d has %s=%v, want %s", i, key, got[key], value)
+			}
+		}
+	}
+}
+
+func TestVInfoDisabled(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.Level = "warn"
+	l, _ := newTestLogger(t, opts)
+
+	if l.V(0).Enabled() {
+		t.Fatal("V(0) enabled with info disabled")
+	}
+}
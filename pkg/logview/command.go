@@ -0,0 +1,31 @@
+package logview
+
+import (
+	"os"
+
+	"github.com/ensn1to/go-pkg/pkg/app"
+)
+
+// NewCommand creates the command rendering JSON logs of files or stdin
+// in the console layout
+func NewCommand() *app.Command {
+	opts := NewOptions()
+
+	return app.NewCommand(
+		"view [FILE...]",
+		"Pretty print and filter JSON log files, gzip rotated files and stdin",
+		app.WithCommandOptions(opts),
+		app.WithCommandRunFunc(func(args []string) error {
+			if errs := opts.Validate(); len(errs) > 0 {
+				return errs[0]
+			}
+
+			viewer, err := NewViewer(opts, os.Stdout)
+			if err != nil {
+				return err
+			}
+
+			return viewer.View(args...)
+		}),
+	)
+}
@@ -0,0 +1,150 @@
+package logview
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	log "git.enn-edge.com/device_manage/public/log.git"
+	"github.com/ensn1to/go-pkg/pkg/app"
+	"go.uber.org/zap/zapcore"
+)
+
+// DecodeOptions of the binary log decoder
+type DecodeOptions struct {
+	// Format of the entries written, json or console
+	Format      string `json:"format" mapstructure:"format"`
+	EnableColor bool   `json:"enable-color" mapstructure:"enable-color"`
+}
+
+// NewDecodeOptions creates the default binary log decoder options
+func NewDecodeOptions() *DecodeOptions {
+	return &DecodeOptions{
+		Format: "json",
+	}
+}
+
+// Flags returns the flags of the binary log decoder
+func (o *DecodeOptions) Flags() (fss app.NamedFlagSets) {
+	fs := fss.FlagSet("output")
+	fs.StringVarP(&o.Format, "output", "o", o.Format, "Output `FORMAT` of the entries, json or console.")
+	fs.BoolVar(&o.EnableColor, "enable-color", o.EnableColor, "Enable output ansi colors in console format.")
+
+	return fss
+}
+
+// Validate checks the binary log decoder options
+func (o *DecodeOptions) Validate() []error {
+	var errs []error
+
+	switch strings.ToLower(o.Format) {
+	case "json", "console":
+	default:
+		errs = append(errs, fmt.Errorf("not a valid output format: %q", o.Format))
+	}
+
+	return errs
+}
+
+// Decoder converts binary log files to JSON or console logs
+type Decoder struct {
+	enc zapcore.Encoder
+	out io.Writer
+}
+
+// NewDecoder creates a decoder writing to out
+func NewDecoder(opts *DecodeOptions, out io.Writer) *Decoder {
+	logOpts := log.NewOptions()
+	logOpts.Format = strings.ToLower(opts.Format)
+	logOpts.EnableColor = opts.EnableColor
+
+	cfg := log.EncoderConfig(logOpts)
+	enc := zapcore.NewConsoleEncoder(cfg)
+	if logOpts.Format == "json" {
+		enc = zapcore.NewJSONEncoder(cfg)
+	}
+
+	return &Decoder{enc: enc, out: out}
+}
+
+// Decode converts the binary log files at paths one after another. Stdin
+// is read if paths is empty or "-".
+func (d *Decoder) Decode(paths ...string) error {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	for _, path := range paths {
+		if err := d.decodeFile(path); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Decoder) decodeFile(path string) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	r, err := decompress(r)
+	if err != nil {
+		return err
+	}
+
+	return d.Render(r)
+}
+
+// Render converts the binary log entries read from r
+func (d *Decoder) Render(r io.Reader) error {
+	br := log.NewBinaryReader(r)
+	for {
+		ent, fields, err := br.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		buf, err := d.enc.EncodeEntry(ent, fields)
+		if err != nil {
+			return err
+		}
+
+		_, err = d.out.Write(buf.Bytes())
+		buf.Free()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// NewDecodeCommand creates the command converting binary logs of files or
+// stdin to JSON or console logs
+func NewDecodeCommand() *app.Command {
+	opts := NewDecodeOptions()
+
+	return app.NewCommand(
+		"decode [FILE...]",
+		"Convert binary log files, gzip rotated files and stdin to JSON or console logs",
+		app.WithCommandOptions(opts),
+		app.WithCommandRunFunc(func(args []string) error {
+			if errs := opts.Validate(); len(errs) > 0 {
+				return errs[0]
+			}
+
+			return NewDecoder(opts, os.Stdout).Decode(args...)
+		}),
+	)
+}
@@ -0,0 +1,145 @@
+package logview
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	log "git.enn-edge.com/device_manage/public/log.git"
+	"github.com/ensn1to/go-pkg/pkg/app"
+)
+
+// DecryptOptions of the encrypted log decrypter
+type DecryptOptions struct {
+	// KeyFile holds the keys, ID=KEY lines, KeyEnv is read if empty
+	KeyFile string `json:"key-file" mapstructure:"key-file"`
+	KeyEnv  string `json:"key-env" mapstructure:"key-env"`
+}
+
+// NewDecryptOptions creates the default encrypted log decrypter options
+func NewDecryptOptions() *DecryptOptions {
+	return &DecryptOptions{
+		KeyEnv: log.DefaultKeyEnv,
+	}
+}
+
+// Flags returns the flags of the encrypted log decrypter
+func (o *DecryptOptions) Flags() (fss app.NamedFlagSets) {
+	fs := fss.FlagSet("keys")
+	fs.StringVar(&o.KeyFile, "key-file", o.KeyFile, "`FILE` of the keys, ID=KEY lines with base64 AES keys.")
+	fs.StringVar(&o.KeyEnv, "key-env", o.KeyEnv, "Environment `VARIABLE` of the keys, read without key file.")
+
+	return fss
+}
+
+// Validate checks the encrypted log decrypter options
+func (o *DecryptOptions) Validate() []error {
+	var errs []error
+
+	if o.KeyFile == "" && o.KeyEnv == "" {
+		errs = append(errs, errors.New("a key file or a key variable is required"))
+	}
+
+	return errs
+}
+
+func (o *DecryptOptions) keyring() (*log.Keyring, error) {
+	if o.KeyFile != "" {
+		return log.LoadKeyring(o.KeyFile)
+	}
+
+	return log.KeyringFromEnv(o.KeyEnv)
+}
+
+// Decrypter writes the plaintext of encrypted log files, which can be
+// piped to the view or decode commands
+type Decrypter struct {
+	keyring *log.Keyring
+	out     io.Writer
+	// errOut reports the segments skipped as their key is unknown
+	errOut io.Writer
+}
+
+// NewDecrypter creates a decrypter writing to out
+func NewDecrypter(opts *DecryptOptions, out io.Writer) (*Decrypter, error) {
+	kr, err := opts.keyring()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Decrypter{keyring: kr, out: out, errOut: os.Stderr}, nil
+}
+
+// skipReporter writes the segments of a file skipped by the decryption
+type skipReporter struct {
+	w    io.Writer
+	path string
+}
+
+func (r skipReporter) OnError(err error) {
+	fmt.Fprintf(r.w, "%s: skipped segment: %v\n", r.path, err)
+}
+
+// Decrypt decrypts the log files at paths one after another. Stdin is
+// read if paths is empty or "-".
+func (d *Decrypter) Decrypt(paths ...string) error {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	for _, path := range paths {
+		if err := d.decryptFile(path); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Decrypter) decryptFile(path string) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	// the rotated files may be compressed after encryption
+	r, err := decompress(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(d.out, log.NewDecryptReader(r, d.keyring, log.WithSkipHandler(skipReporter{w: d.errOut, path: path})))
+
+	return err
+}
+
+// NewDecryptCommand creates the command writing the plaintext of encrypted
+// log files or stdin
+func NewDecryptCommand() *app.Command {
+	opts := NewDecryptOptions()
+
+	return app.NewCommand(
+		"decrypt [FILE...]",
+		"Decrypt encrypted log files, gzip rotated files and stdin, e.g. logview decrypt app.log | logview view",
+		app.WithCommandOptions(opts),
+		app.WithCommandRunFunc(func(args []string) error {
+			if errs := opts.Validate(); len(errs) > 0 {
+				return errs[0]
+			}
+
+			decrypter, err := NewDecrypter(opts, os.Stdout)
+			if err != nil {
+				return err
+			}
+
+			return decrypter.Decrypt(args...)
+		}),
+	)
+}
@@ -0,0 +1,147 @@
+package logview
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	log "git.enn-edge.com/device_manage/public/log.git"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// entry is a log entry decoded from a JSON line
+type entry struct {
+	zapcore.Entry
+	// fields keep the order of the line
+	fields []field
+}
+
+type field struct {
+	key string
+	raw json.RawMessage
+}
+
+// text returns the field value as text, strings without quotes
+func (f field) text() string {
+	var s string
+	if len(f.raw) > 0 && f.raw[0] == '"' && json.Unmarshal(f.raw, &s) == nil {
+		return s
+	}
+
+	return string(f.raw)
+}
+
+// zapField converts the field back into a zap field
+func (f field) zapField() zapcore.Field {
+	if len(f.raw) == 0 {
+		return zap.Skip()
+	}
+
+	switch f.raw[0] {
+	case '"':
+		return zap.String(f.key, f.text())
+	case 't', 'f':
+		return zap.Bool(f.key, f.raw[0] == 't')
+	case 'n':
+		return zap.Reflect(f.key, nil)
+	case '{', '[':
+		return zap.Reflect(f.key, f.raw)
+	}
+
+	if i, err := strconv.ParseInt(string(f.raw), 10, 64); err == nil {
+		return zap.Int64(f.key, i)
+	}
+	if v, err := strconv.ParseFloat(string(f.raw), 64); err == nil {
+		return zap.Float64(f.key, v)
+	}
+
+	return zap.String(f.key, string(f.raw))
+}
+
+// lookup returns the field named key
+func (e *entry) lookup(key string) (field, bool) {
+	for _, f := range e.fields {
+		if f.key == key {
+			return f, true
+		}
+	}
+
+	return field{}, false
+}
+
+// parseEntry decodes a JSON line written with the encoder config cfg
+func parseEntry(line []byte, cfg zapcore.EncoderConfig) (*entry, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("not a JSON object")
+	}
+
+	e := &entry{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		f := field{key: key, raw: raw}
+
+		switch key {
+		case cfg.LevelKey:
+			if e.Level, err = log.ParseLevel(f.text()); err != nil {
+				return nil, err
+			}
+		case cfg.TimeKey:
+			e.Time, _ = parseEntryTime(f.text())
+		case cfg.NameKey:
+			e.LoggerName = f.text()
+		case cfg.MessageKey:
+			e.Message = f.text()
+		case cfg.StacktraceKey:
+			e.Stack = f.text()
+		case cfg.CallerKey:
+			e.Caller = parseCaller(f.text())
+		default:
+			e.fields = append(e.fields, f)
+		}
+	}
+
+	return e, nil
+}
+
+func parseCaller(s string) zapcore.EntryCaller {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return zapcore.EntryCaller{Defined: true, File: s}
+	}
+
+	line, _ := strconv.Atoi(s[i+1:])
+
+	return zapcore.EntryCaller{Defined: true, File: s[:i], Line: line}
+}
+
+// timeLayouts are tried in order to parse the timestamps of entries
+var timeLayouts = []string{
+	log.TimeLayout,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.000Z0700",
+	"2006-01-02 15:04:05",
+}
+
+func parseEntryTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
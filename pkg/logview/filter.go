@@ -0,0 +1,91 @@
+package logview
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// keyTraceID is the field J adds for the trace of a span
+const keyTraceID = "trace_id"
+
+type fieldMatcher struct {
+	key   string
+	value string
+	re    *regexp.Regexp
+}
+
+func (m fieldMatcher) match(e *entry) bool {
+	f, ok := e.lookup(m.key)
+	if !ok {
+		return false
+	}
+
+	if m.re != nil {
+		return m.re.MatchString(f.text())
+	}
+
+	return f.text() == m.value
+}
+
+// filter selects the entries shown
+type filter struct {
+	level   zapcore.LevelEnabler
+	names   []string
+	since   time.Time
+	until   time.Time
+	fields  []fieldMatcher
+	traceID string
+}
+
+func (f *filter) match(e *entry) bool {
+	if !f.level.Enabled(e.Level) {
+		return false
+	}
+
+	if !f.since.IsZero() && e.Time.Before(f.since) {
+		return false
+	}
+
+	if !f.until.IsZero() && e.Time.After(f.until) {
+		return false
+	}
+
+	if len(f.names) > 0 && !matchName(e.LoggerName, f.names) {
+		return false
+	}
+
+	if f.traceID != "" {
+		if tf, ok := e.lookup(keyTraceID); !ok || tf.text() != f.traceID {
+			return false
+		}
+	}
+
+	for _, m := range f.fields {
+		if !m.match(e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchName reports whether name is one of names or a child of it
+func matchName(name string, names []string) bool {
+	for _, n := range names {
+		if name == n || strings.HasPrefix(name, n+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selective reports whether the filter selects on more than the level,
+// lines which aren't log entries are hidden then
+func (f *filter) selective() bool {
+	return len(f.names) > 0 || len(f.fields) > 0 || f.traceID != "" ||
+		!f.since.IsZero() || !f.until.IsZero()
+}
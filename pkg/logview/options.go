@@ -0,0 +1,152 @@
+package logview
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	log "git.enn-edge.com/device_manage/public/log.git"
+	"github.com/ensn1to/go-pkg/pkg/app"
+)
+
+// Options of the log viewer
+type Options struct {
+	// Level minimum level of the entries shown
+	Level string `json:"level" mapstructure:"level"`
+	// Names logger names shown, including their children
+	Names []string `json:"names" mapstructure:"names"`
+	// Since and Until bound the time of the entries shown, either a time
+	// or a duration before now
+	Since string `json:"since" mapstructure:"since"`
+	Until string `json:"until" mapstructure:"until"`
+	// Fields key=value for equality, key~=regexp for a match
+	Fields []string `json:"fields" mapstructure:"fields"`
+	// TraceID shows only the entries of a trace
+	TraceID     string `json:"trace-id" mapstructure:"trace-id"`
+	Follow      bool   `json:"follow" mapstructure:"follow"`
+	EnableColor bool   `json:"enable-color" mapstructure:"enable-color"`
+	// Development renders the entries in the development console layout
+	Development bool `json:"development" mapstructure:"development"`
+}
+
+// NewOptions creates the default log viewer options
+func NewOptions() *Options {
+	return &Options{
+		Level:       log.LevelString(log.TraceLevel),
+		EnableColor: true,
+	}
+}
+
+// Flags returns the flags of the log viewer
+func (o *Options) Flags() (fss app.NamedFlagSets) {
+	fs := fss.FlagSet("filter")
+	fs.StringVarP(&o.Level, "level", "l", o.Level, "Minimum `LEVEL` of the entries shown, trace and notice included.")
+	fs.StringSliceVarP(&o.Names, "name", "n", o.Names, "Show only the entries of the logger `NAME` and its children.")
+	fs.StringVar(&o.Since, "since", o.Since,
+		"Show entries not older than `TIME`, a timestamp or a duration before now, e.g. 1h.")
+	fs.StringVar(&o.Until, "until", o.Until,
+		"Show entries not newer than `TIME`, a timestamp or a duration before now, e.g. 1h.")
+	fs.StringSliceVarP(&o.Fields, "field", "f", o.Fields,
+		"Show only the entries whose field matches, key=value for equality or key~=regexp.")
+	fs.StringVar(&o.TraceID, "trace-id", o.TraceID, "Show only the entries of the trace `ID`.")
+
+	fs = fss.FlagSet("output")
+	fs.BoolVarP(&o.Follow, "follow", "F", o.Follow, "Keep reading the last file as it grows.")
+	fs.BoolVar(&o.EnableColor, "enable-color", o.EnableColor, "Enable output ansi colors.")
+	fs.BoolVar(&o.Development, "development", o.Development,
+		"Render the entries in the development console layout, as a logger in development writes them.")
+
+	return fss
+}
+
+// Validate checks the log viewer options
+func (o *Options) Validate() []error {
+	var errs []error
+
+	if _, err := log.ParseLevel(o.Level); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := parseTime(o.Since, time.Now()); err != nil {
+		errs = append(errs, fmt.Errorf("invalid since: %w", err))
+	}
+
+	if _, err := parseTime(o.Until, time.Now()); err != nil {
+		errs = append(errs, fmt.Errorf("invalid until: %w", err))
+	}
+
+	for _, field := range o.Fields {
+		if _, err := parseFieldMatcher(field); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// filter builds the entry filter from the options
+func (o *Options) filter() (*filter, error) {
+	f := &filter{
+		names:   o.Names,
+		traceID: o.TraceID,
+	}
+
+	level, err := log.ParseLevel(o.Level)
+	if err != nil {
+		return nil, err
+	}
+	f.level = log.NewLevelEnabler(level)
+
+	now := time.Now()
+	if f.since, err = parseTime(o.Since, now); err != nil {
+		return nil, err
+	}
+	if f.until, err = parseTime(o.Until, now); err != nil {
+		return nil, err
+	}
+
+	for _, field := range o.Fields {
+		m, err := parseFieldMatcher(field)
+		if err != nil {
+			return nil, err
+		}
+		f.fields = append(f.fields, m)
+	}
+
+	return f, nil
+}
+
+// parseTime parses a timestamp or a duration before now, empty is zero
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	if t, ok := parseEntryTime(s); ok {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("neither a time nor a duration: %q", s)
+}
+
+func parseFieldMatcher(s string) (fieldMatcher, error) {
+	if i := strings.Index(s, "~="); i > 0 {
+		re, err := regexp.Compile(s[i+2:])
+		if err != nil {
+			return fieldMatcher{}, fmt.Errorf("invalid field regexp %q: %w", s, err)
+		}
+
+		return fieldMatcher{key: s[:i], re: re}, nil
+	}
+
+	if i := strings.Index(s, "="); i > 0 {
+		return fieldMatcher{key: s[:i], value: s[i+1:]}, nil
+	}
+
+	return fieldMatcher{}, fmt.Errorf("invalid field filter %q, expect key=value or key~=regexp", s)
+}
@@ -0,0 +1,173 @@
+package logview
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	log "git.enn-edge.com/device_manage/public/log.git"
+	"go.uber.org/zap/zapcore"
+)
+
+// followInterval is the time waited for a followed file to grow
+const followInterval = 200 * time.Millisecond
+
+// Viewer renders JSON log entries in the console layout of log.New, the
+// one of development if the viewer options set it
+type Viewer struct {
+	filter *filter
+	follow bool
+	// cfg is the encoder config of the JSON entries read
+	cfg zapcore.EncoderConfig
+	enc zapcore.Encoder
+	out io.Writer
+}
+
+// NewViewer creates a viewer writing to out
+func NewViewer(opts *Options, out io.Writer) (*Viewer, error) {
+	f, err := opts.filter()
+	if err != nil {
+		return nil, err
+	}
+
+	logOpts := log.NewOptions()
+	logOpts.Development = opts.Development
+	logOpts.EnableColor = opts.EnableColor
+
+	return &Viewer{
+		filter: f,
+		follow: opts.Follow,
+		cfg:    log.EncoderConfig(log.NewOptions()),
+		enc:    log.NewEncoder(logOpts),
+		out:    out,
+	}, nil
+}
+
+// View renders the log files at paths one after another, the last one is
+// followed in follow mode. Stdin is read if paths is empty or "-".
+func (v *Viewer) View(paths ...string) error {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	for i, path := range paths {
+		if err := v.viewFile(path, v.follow && i == len(paths)-1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *Viewer) viewFile(path string, follow bool) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r = f
+		if follow {
+			r = &followReader{r: f}
+		}
+	}
+
+	r, err := decompress(r)
+	if err != nil {
+		return err
+	}
+
+	return v.Render(r)
+}
+
+// Render renders the log entries read from r
+func (v *Viewer) Render(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if werr := v.renderLine(line); werr != nil {
+				return werr
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (v *Viewer) renderLine(line []byte) error {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return nil
+	}
+
+	e, err := parseEntry(line, v.cfg)
+	if err != nil {
+		// lines which aren't log entries, e.g. written by the runtime on a crash
+		if v.filter.selective() {
+			return nil
+		}
+		_, err = v.out.Write(append(line, '\n'))
+
+		return err
+	}
+
+	if !v.filter.match(e) {
+		return nil
+	}
+
+	fields := make([]zapcore.Field, 0, len(e.fields))
+	for _, f := range e.fields {
+		fields = append(fields, f.zapField())
+	}
+
+	buf, err := v.enc.EncodeEntry(e.Entry, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	_, err = v.out.Write(buf.Bytes())
+
+	return err
+}
+
+// decompress transparently decompresses gzip rotated files
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil
+	}
+
+	return gzip.NewReader(br)
+}
+
+// followReader waits for more data at the end of the file instead of
+// returning io.EOF
+type followReader struct {
+	r io.Reader
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		if n > 0 || !errors.Is(err, io.EOF) {
+			return n, err
+		}
+
+		time.Sleep(followInterval)
+	}
+}
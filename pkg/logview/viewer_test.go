@@ -0,0 +1,165 @@
+package logview
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testLog = `{"level":"TRACE","timestamp":"2024-05-01 10:00:00.000","logger":"api","message":"trace entry"}
+{"level":"DEBUG","timestamp":"2024-05-01 10:00:01.000","logger":"api.db","message":"debug entry","query":"select 1"}
+{"level":"INFO","timestamp":"2024-05-01 10:00:02.000","logger":"worker","message":"info entry","trace_id":"abc","user":{"id":1,"name":"bob"}}
+panic: runtime error
+{"level":"ERROR","timestamp":"2024-05-01 10:00:03.000","logger":"api","message":"error entry","status":500}
+`
+
+func render(t *testing.T, configure func(*Options)) string {
+	t.Helper()
+
+	opts := NewOptions()
+	opts.EnableColor = false
+	if configure != nil {
+		configure(opts)
+	}
+	if errs := opts.Validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	var out bytes.Buffer
+	viewer, err := NewViewer(opts, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := viewer.Render(strings.NewReader(testLog)); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.String()
+}
+
+func TestViewerFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*Options)
+		want      []string
+	}{
+		{
+			name: "default",
+			want: []string{"trace entry", "debug entry", "info entry", "panic: runtime error", "error entry"},
+		},
+		{
+			name:      "level",
+			configure: func(o *Options) { o.Level = "info" },
+			want:      []string{"info entry", "panic: runtime error", "error entry"},
+		},
+		{
+			name:      "name",
+			configure: func(o *Options) { o.Names = []string{"api"} },
+			want:      []string{"trace entry", "debug entry", "error entry"},
+		},
+		{
+			name:      "field",
+			configure: func(o *Options) { o.Fields = []string{"status=500"} },
+			want:      []string{"error entry"},
+		},
+		{
+			name:      "field regexp",
+			configure: func(o *Options) { o.Fields = []string{"query~=^select"} },
+			want:      []string{"debug entry"},
+		},
+		{
+			name:      "trace",
+			configure: func(o *Options) { o.TraceID = "abc" },
+			want:      []string{"info entry"},
+		},
+		{
+			name: "time",
+			configure: func(o *Options) {
+				o.Since = "2024-05-01 10:00:01.000"
+				o.Until = "2024-05-01 10:00:02.000"
+			},
+			want: []string{"debug entry", "info entry"},
+		},
+	}
+
+	all := []string{"trace entry", "debug entry", "info entry", "panic: runtime error", "error entry"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := render(t, tt.configure)
+			for _, msg := range all {
+				want := false
+				for _, w := range tt.want {
+					want = want || w == msg
+				}
+				if strings.Contains(out, msg) != want {
+					t.Fatalf("%q shown: %v, want %v in\n%s", msg, !want, want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestViewerLayout(t *testing.T) {
+	out := render(t, func(o *Options) { o.Level = "info" })
+	lines := strings.Split(out, "\n")
+
+	// the layout of the console of log.New, fields as JSON
+	if want := "2024-05-01 10:00:02.000\tINFO\tworker\tinfo entry\t"; !strings.HasPrefix(lines[0], want) {
+		t.Fatalf("unexpected layout %q", lines[0])
+	}
+	if !strings.Contains(lines[0], `"trace_id": "abc"`) || !strings.Contains(lines[0], `"name":"bob"`) {
+		t.Fatalf("fields not rendered:\n%s", out)
+	}
+	if strings.Contains(out, "\x1b[") {
+		t.Fatalf("colored output:\n%q", out)
+	}
+}
+
+func TestViewerDevelopmentLayout(t *testing.T) {
+	out := render(t, func(o *Options) {
+		o.Level = "info"
+		o.Development = true
+	})
+	lines := strings.Split(out, "\n")
+
+	// the layout of the development console, objects as indented JSON
+	if !strings.HasPrefix(lines[0], "2024-05-01 10:00:02.000 INFO   worker: info entry") {
+		t.Fatalf("unexpected layout %q", lines[0])
+	}
+	if !strings.Contains(lines[0], "trace_id=abc") || !strings.Contains(out, `"name": "bob"`) {
+		t.Fatalf("fields not rendered:\n%s", out)
+	}
+	if strings.Contains(out, "\x1b[") {
+		t.Fatalf("colored output:\n%q", out)
+	}
+}
+
+func TestViewerGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, _ = zw.Write([]byte(testLog))
+	zw.Close()
+
+	path := filepath.Join(t.TempDir(), "app.log.gz")
+	if err := os.WriteFile(path, compressed.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := NewOptions()
+	opts.Level = "error"
+	var out bytes.Buffer
+	viewer, err := NewViewer(opts, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := viewer.View(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "error entry") || strings.Contains(out.String(), "info entry") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,68 @@
+package shutdown
+
+import (
+	"context"
+	"sync"
+)
+
+const TriggerManagerName = "TriggerManager"
+
+// TriggerManager is a kind of ShutdownManager which is started by
+// calling Trigger, e.g. by a logger before exiting on a fatal error.
+// Unlike PosixSignalManager it doesn't exit the process itself.
+type TriggerManager struct {
+	mu sync.Mutex
+	gs GracefulShutdowner
+}
+
+// NewTriggerManager initializes the TriggerManager
+func NewTriggerManager() *TriggerManager {
+	return &TriggerManager{}
+}
+
+func (triggerManager *TriggerManager) GetName() string {
+	return TriggerManagerName
+}
+
+// Start keeps the GracefulShutdowner to shutdown when triggered
+func (triggerManager *TriggerManager) Start(gs GracefulShutdowner) error {
+	triggerManager.mu.Lock()
+	defer triggerManager.mu.Unlock()
+
+	triggerManager.gs = gs
+
+	return nil
+}
+
+// Trigger starts the shutdown and blocks until all shutdown callbacks
+// have finished or ctx is done.
+func (triggerManager *TriggerManager) Trigger(ctx context.Context) error {
+	triggerManager.mu.Lock()
+	gs := triggerManager.gs
+	triggerManager.mu.Unlock()
+
+	if gs == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gs.StartShutdown(triggerManager)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (triggerManager *TriggerManager) ShutdownStart() error {
+	return nil
+}
+
+func (triggerManager *TriggerManager) ShutdownFinish() error {
+	return nil
+}